@@ -0,0 +1,150 @@
+package gorilla
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Analysis reports where the bits of a block go.
+type Analysis struct {
+	Header uint32 `json:"header"`
+	// Bytes is the size of the block including the header and the finish marker.
+	Bytes  int `json:"bytes"`
+	Points int `json:"points"`
+	// TimestampBits and ValueBits are the bits spent on the timestamps and the
+	// values of the points, excluding the header and the finish marker.
+	TimestampBits int `json:"timestampBits"`
+	ValueBits     int `json:"valueBits"`
+	// DodBuckets counts the points after the first by the bucket their delta
+	// of delta is encoded in: '0', '10', '110', '1110' and '1111'.
+	DodBuckets [5]int `json:"dodBuckets"`
+	// IdenticalValues, ReusedWindows and NewWindows count the values after the
+	// first which are identical to the previous one, whose XOR fits in the
+	// previous meaningful bits window, and whose XOR is stored with a new window.
+	IdenticalValues int `json:"identicalValues"`
+	ReusedWindows   int `json:"reusedWindows"`
+	NewWindows      int `json:"newWindows"`
+	// MeaningfulBits is the sum of the meaningful bits of the XORs stored in
+	// reused and new windows.
+	MeaningfulBits int `json:"meaningfulBits"`
+}
+
+// BytesPerPoint returns the bytes per point of the block, which the paper
+// reports to be 1.37 on average for two-hour blocks.
+func (a Analysis) BytesPerPoint() float64 {
+	if a.Points == 0 {
+		return 0
+	}
+	return float64(a.Bytes) / float64(a.Points)
+}
+
+// AvgMeaningfulBits returns the average number of meaningful bits of the
+// values whose XOR is stored.
+func (a Analysis) AvgMeaningfulBits() float64 {
+	if n := a.ReusedWindows + a.NewWindows; n != 0 {
+		return float64(a.MeaningfulBits) / float64(n)
+	}
+	return 0
+}
+
+func (a *Analysis) add(p *pointTrace) {
+	a.Points++
+	if p.valueCase == valueFirst {
+		a.TimestampBits += firstDeltaBits
+		a.ValueBits += 64
+		return
+	}
+	a.TimestampBits += len(p.dodControl()) + int(p.dodBits)
+	a.DodBuckets[dodBucket(p.dodBits)]++
+	switch p.valueCase {
+	case valueSame:
+		a.IdenticalValues++
+		a.ValueBits++
+	case valueReuse:
+		a.ReusedWindows++
+		a.MeaningfulBits += int(p.significantBits())
+		a.ValueBits += 2 + int(p.significantBits())
+	case valueNew:
+		a.NewWindows++
+		a.MeaningfulBits += int(p.significantBits())
+		a.ValueBits += 2 + 5 + 6 + int(p.significantBits())
+	}
+}
+
+// WriteTo writes a as a human-readable report to w.
+func (a Analysis) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	fmt.Fprintf(bw, "header           %d (%s)\n", a.Header, formatUnix(a.Header))
+	fmt.Fprintf(bw, "points           %d\n", a.Points)
+	fmt.Fprintf(bw, "bytes            %d (%.2f bytes/point)\n", a.Bytes, a.BytesPerPoint())
+	if 0 < a.Points {
+		fmt.Fprintf(bw, "timestamp bits   %d (%.2f bits/point)\n", a.TimestampBits, float64(a.TimestampBits)/float64(a.Points))
+		fmt.Fprintf(bw, "value bits       %d (%.2f bits/point)\n", a.ValueBits, float64(a.ValueBits)/float64(a.Points))
+		fmt.Fprintf(bw, "meaningful bits  %.2f on average\n", a.AvgMeaningfulBits())
+		for i, bucket := range dodBuckets {
+			fmt.Fprintf(bw, "  dod %-6s %-19s %6d %s\n", "'"+bucket.control+"'", bucket.desc, a.DodBuckets[i], share(a.DodBuckets[i], a.Points-1))
+		}
+		for _, c := range []struct {
+			control, desc string
+			n             int
+		}{
+			{"0", "identical", a.IdenticalValues},
+			{"10", "previous window", a.ReusedWindows},
+			{"11", "new window", a.NewWindows},
+		} {
+			fmt.Fprintf(bw, "  xor %-6s %-19s %6d %s\n", "'"+c.control+"'", c.desc, c.n, share(c.n, a.Points-1))
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+	// err is the last error of r other than io.EOF.
+	err error
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
+	return n, err
+}
+
+// Analyze decompresses the block read from r and reports where its bits go.
+// It returns ErrTruncatedBlock if the block has no finish marker.
+func Analyze(r io.Reader) (Analysis, error) {
+	cr := &countingReader{r: r}
+	d, header, err := NewDecompressor(cr)
+	if err != nil {
+		return Analysis{}, err
+	}
+	a := Analysis{Header: header}
+	_, err = d.traceAll(a.add)
+	if !errors.Is(err, errEndOfBlock) {
+		if errors.Is(err, io.EOF) {
+			err = ErrTruncatedBlock
+		}
+		return Analysis{}, fmt.Errorf("failed to decompress: %w", err)
+	}
+	a.Bytes = cr.n
+	return a, nil
+}
@@ -0,0 +1,64 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Analyze(t *testing.T) {
+	b := compress(t, 1600000000, []point{
+		{1600000010, 1.5},
+		{1600000070, 1.5},
+		{1600000131, 2},
+		{1600000191, 2.5},
+		{1600005191, 2.5},
+	})
+	a, err := gorilla.Analyze(bytes.NewReader(b))
+	require.Nil(t, err)
+	assert.Equal(t, gorilla.Analysis{
+		Header:          1600000000,
+		Bytes:           32,
+		Points:          5,
+		TimestampBits:   14 + 9 + 9 + 9 + 36,
+		ValueBits:       64 + 1 + (2 + 5 + 6 + 12) + (2 + 5 + 6 + 1) + 1,
+		DodBuckets:      [5]int{0, 3, 0, 0, 1},
+		IdenticalValues: 2,
+		NewWindows:      2,
+		MeaningfulBits:  13,
+	}, a)
+	assert.Equal(t, len(b), a.Bytes)
+	assert.Equal(t, 6.4, a.BytesPerPoint())
+	assert.Equal(t, 6.5, a.AvgMeaningfulBits())
+	assert.Equal(t, 32*8, 32+a.TimestampBits+a.ValueBits+37+5, "header, points, finish marker and padding")
+
+	var buf bytes.Buffer
+	_, err = a.WriteTo(&buf)
+	require.Nil(t, err)
+	assert.Contains(t, buf.String(), "bytes            32 (6.40 bytes/point)\n")
+	assert.Contains(t, buf.String(), "meaningful bits  6.50 on average\n")
+}
+
+func Test_Analyze_regular(t *testing.T) {
+	// A regular interval and a constant value need 2 bits per point.
+	points := make([]point, 7200)
+	for i := range points {
+		points[i] = point{1600000000 + uint32(i)*10, 42}
+	}
+	a, err := gorilla.Analyze(bytes.NewReader(compress(t, 1600000000, points)))
+	require.Nil(t, err)
+	assert.Equal(t, 7199, a.IdenticalValues)
+	assert.Equal(t, [5]int{7198, 1, 0, 0, 0}, a.DodBuckets)
+	assert.Less(t, a.BytesPerPoint(), 0.3)
+	assert.Equal(t, 0.0, a.AvgMeaningfulBits())
+}
+
+func Test_Analyze_truncated(t *testing.T) {
+	b := compress(t, 1600000000, series(1600000000, 10, 60, 1))
+	_, err := gorilla.Analyze(bytes.NewReader(b[:len(b)-1]))
+	assert.True(t, errors.Is(err, gorilla.ErrTruncatedBlock), err)
+}
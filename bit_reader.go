@@ -10,7 +10,8 @@ import (
 type bitReader struct {
 	r      io.Reader
 	buffer [1]byte
-	count  uint8 // The number of right-most bits valid to read (from left) in the current 8 byte buffer.
+	count  uint8  // The number of right-most bits valid to read (from left) in the current 8 byte buffer.
+	read   uint64 // The number of bits read so far.
 }
 
 // newReader returns a reader that returns a single bit at a time from 'r'
@@ -32,6 +33,7 @@ func (b *bitReader) readBit() (bit, error) {
 		b.count = 8
 	}
 	b.count--
+	b.read++
 	// bitwise AND
 	// (e.g.)
 	// 11111111 & 10000000 = 10000000
@@ -53,6 +55,7 @@ func (b *bitReader) readByte() (byte, error) {
 		if n != 1 {
 			return b.buffer[0], errors.New("read more than a byte")
 		}
+		b.read += 8
 		return b.buffer[0], nil
 	}
 
@@ -68,6 +71,7 @@ func (b *bitReader) readByte() (byte, error) {
 
 	byt |= b.buffer[0] >> b.count
 	b.buffer[0] <<= (8 - b.count)
+	b.read += 8
 
 	return byt, nil
 }
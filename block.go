@@ -0,0 +1,110 @@
+package gorilla
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// ErrTruncatedBlock is returned when a block ends before its finish marker.
+var ErrTruncatedBlock = errors.New("truncated block")
+
+// Block is a series of points compressed by Compressor.
+//
+// Block implements encoding.BinaryMarshaler, gob.GobEncoder, sql.Scanner and
+// driver.Valuer as its bytes, so it can be stored in binary columns and caches.
+// Decoding methods validate the bytes so that corrupted blocks are reported
+// when they are read rather than while iterating over them.
+type Block []byte
+
+// BlockStats describes the points of a block.
+type BlockStats struct {
+	Header  uint32 `json:"header"`
+	Count   int    `json:"count"`
+	MinTime uint32 `json:"minTime"`
+	MaxTime uint32 `json:"maxTime"`
+}
+
+// Decompressor returns a decompressor of b.
+func (b Block) Decompressor() (*Decompressor, error) {
+	d, _, err := NewDecompressor(bytes.NewReader(b))
+	return d, err
+}
+
+// Stats decompresses b and returns the stats of its points.
+// It returns ErrTruncatedBlock if b has no finish marker.
+func (b Block) Stats() (BlockStats, error) {
+	d, header, err := NewDecompressor(bytes.NewReader(b))
+	if err != nil {
+		return BlockStats{}, err
+	}
+	stats := BlockStats{Header: header}
+	iter := d.Iterator()
+	for iter.Next() {
+		t, _ := iter.At()
+		if stats.Count == 0 {
+			stats.MinTime = t
+		}
+		stats.MaxTime = t
+		stats.Count++
+	}
+	if err := iter.Err(); err != nil {
+		return BlockStats{}, fmt.Errorf("failed to decompress: %w", err)
+	}
+	return stats, nil
+}
+
+// Validate decompresses b and returns an error if b is corrupted or truncated.
+func (b Block) Validate() error {
+	_, err := b.Stats()
+	return err
+}
+
+// MarshalBinary returns the bytes of b.
+func (b Block) MarshalBinary() ([]byte, error) {
+	return b, nil
+}
+
+// UnmarshalBinary validates data and copies it into b.
+func (b *Block) UnmarshalBinary(data []byte) error {
+	if err := Block(data).Validate(); err != nil {
+		return fmt.Errorf("invalid block: %w", err)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// GobEncode returns the bytes of b.
+func (b Block) GobEncode() ([]byte, error) {
+	return b.MarshalBinary()
+}
+
+// GobDecode validates data and copies it into b.
+func (b *Block) GobDecode(data []byte) error {
+	return b.UnmarshalBinary(data)
+}
+
+// Scan validates a []byte or string from a database and copies it into b.
+// A NULL value is scanned as a nil block.
+func (b *Block) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		return b.UnmarshalBinary(src)
+	case string:
+		return b.UnmarshalBinary([]byte(src))
+	default:
+		return fmt.Errorf("cannot scan %T into Block", src)
+	}
+}
+
+// Value returns the bytes of b, or NULL if b is nil.
+func (b Block) Value() (driver.Value, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return []byte(b), nil
+}
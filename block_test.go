@@ -0,0 +1,83 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Block_Stats(t *testing.T) {
+	b := gorilla.Block(compress(t, 1600000000, series(1600000010, 3, 60, 1)))
+	stats, err := b.Stats()
+	require.Nil(t, err)
+	assert.Equal(t, gorilla.BlockStats{Header: 1600000000, Count: 3, MinTime: 1600000010, MaxTime: 1600000130}, stats)
+
+	stats, err = gorilla.Block(compress(t, 1600000000, nil)).Stats()
+	require.Nil(t, err)
+	assert.Equal(t, gorilla.BlockStats{Header: 1600000000}, stats)
+
+	_, err = gorilla.Block{0x01}.Stats()
+	assert.NotNil(t, err)
+	for _, n := range []int{4, 10, len(b) - 1} {
+		_, err = b[:n].Stats()
+		assert.True(t, errors.Is(err, gorilla.ErrTruncatedBlock), "truncated at %d: %v", n, err)
+	}
+}
+
+func Test_Block_binary(t *testing.T) {
+	b := gorilla.Block(compress(t, 1600000000, series(1600000000, 10, 60, 1)))
+	data, err := b.MarshalBinary()
+	require.Nil(t, err)
+
+	var decoded gorilla.Block
+	require.Nil(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, b, decoded)
+	data[0] = 0xFF
+	assert.NotEqual(t, data[0], decoded[0], "decoded block must not share memory with data")
+
+	err = decoded.UnmarshalBinary(b[:len(b)-1])
+	assert.True(t, errors.Is(err, gorilla.ErrTruncatedBlock))
+}
+
+func Test_Block_gob(t *testing.T) {
+	type cached struct {
+		Series string
+		Block  gorilla.Block
+	}
+	want := cached{"cpu", gorilla.Block(compress(t, 1600000000, series(1600000000, 10, 60, 1)))}
+	var buf bytes.Buffer
+	require.Nil(t, gob.NewEncoder(&buf).Encode(want))
+	var got cached
+	require.Nil(t, gob.NewDecoder(&buf).Decode(&got))
+	assert.Equal(t, want, got)
+
+	buf.Reset()
+	require.Nil(t, gob.NewEncoder(&buf).Encode(cached{"cpu", want.Block[:5]}))
+	assert.NotNil(t, gob.NewDecoder(&buf).Decode(&got))
+}
+
+func Test_Block_sql(t *testing.T) {
+	b := gorilla.Block(compress(t, 1600000000, series(1600000000, 10, 60, 1)))
+	v, err := b.Value()
+	require.Nil(t, err)
+	assert.Equal(t, []byte(b), v)
+	v, err = gorilla.Block(nil).Value()
+	require.Nil(t, err)
+	assert.Nil(t, v)
+
+	var scanned gorilla.Block
+	require.Nil(t, scanned.Scan([]byte(b)))
+	assert.Equal(t, b, scanned)
+	require.Nil(t, scanned.Scan(string(b)))
+	assert.Equal(t, b, scanned)
+	require.Nil(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+
+	assert.NotNil(t, scanned.Scan([]byte{0x01, 0x02}))
+	assert.NotNil(t, scanned.Scan(42))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/generator"
+)
+
+var benchCommand = &command{
+	name:  "bench",
+	usage: "measure compression of synthetic workloads",
+}
+
+func init() {
+	benchCommand.run = runBench
+}
+
+// benchResult is the result of a workload.
+type benchResult struct {
+	Workload      string  `json:"workload"`
+	Points        int     `json:"points"`
+	Bytes         int     `json:"bytes"`
+	BytesPerPoint float64 `json:"bytesPerPoint"`
+	// CompressRate and DecompressRate are in points per second.
+	CompressRate   float64 `json:"compressRate"`
+	DecompressRate float64 `json:"decompressRate"`
+}
+
+func runBench(args []string, stdio stdio) error {
+	fs := newFlagSet(benchCommand, "", stdio.err)
+	points := fs.Int("points", 720, "points per series, 720 at 10 second intervals being a two-hour block")
+	seed := fs.Int64("seed", 1, "seed of the workloads")
+	duration := fs.Duration("duration", time.Second, "minimum duration to measure each of compression and decompression")
+	output := fs.String("o", "", "write the results as JSON to the file, for diffing across commits")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	var results []benchResult
+	for _, w := range generator.Workloads(*points, *seed) {
+		r, err := bench(w, *duration)
+		if err != nil {
+			return fmt.Errorf("%s: %w", w.Name, err)
+		}
+		results = append(results, r)
+	}
+
+	tw := tabwriter.NewWriter(stdio.out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "WORKLOAD\tPOINTS\tBYTES\tBYTES/POINT\tCOMPRESS\tDECOMPRESS\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%.1f Mpoints/s\t%.1f Mpoints/s\t\n",
+			r.Workload, r.Points, r.Bytes, r.BytesPerPoint, r.CompressRate/1e6, r.DecompressRate/1e6)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return nil
+	}
+	out, err := createOutput(*output, stdio.out)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func bench(w generator.Workload, duration time.Duration) (benchResult, error) {
+	points := w.Generate()
+	if len(points) == 0 {
+		return benchResult{}, fmt.Errorf("no points generated")
+	}
+	buf := new(bytes.Buffer)
+	compress := func() error {
+		buf.Reset()
+		c, finish, err := gorilla.NewCompressor(buf, points[0].T)
+		if err != nil {
+			return err
+		}
+		for _, p := range points {
+			if err := c.Compress(p.T, p.V); err != nil {
+				return err
+			}
+		}
+		return finish()
+	}
+	compressRate, err := measure(len(points), duration, compress)
+	if err != nil {
+		return benchResult{}, err
+	}
+	block := append([]byte(nil), buf.Bytes()...)
+
+	decompressRate, err := measure(len(points), duration, func() error {
+		d, _, err := gorilla.NewDecompressor(bytes.NewReader(block))
+		if err != nil {
+			return err
+		}
+		n := 0
+		iter := d.Iterator()
+		for iter.Next() {
+			n++
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		if n != len(points) {
+			return fmt.Errorf("decompressed %d points, want %d", n, len(points))
+		}
+		return nil
+	})
+	if err != nil {
+		return benchResult{}, err
+	}
+
+	return benchResult{
+		Workload:       w.Name,
+		Points:         len(points),
+		Bytes:          len(block),
+		BytesPerPoint:  float64(len(block)) / float64(len(points)),
+		CompressRate:   compressRate,
+		DecompressRate: decompressRate,
+	}, nil
+}
+
+// measure runs fn processing n points at least once and until duration passes,
+// and returns the points processed per second.
+func measure(n int, duration time.Duration, fn func() error) (float64, error) {
+	var runs int
+	start := time.Now()
+	for runs == 0 || time.Since(start) < duration {
+		if err := fn(); err != nil {
+			return 0, err
+		}
+		runs++
+	}
+	return float64(n*runs) / time.Since(start).Seconds(), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/keisku/gorilla"
+)
+
+var compressCommand = &command{
+	name:  "compress",
+	usage: "compress points of CSV, TSV or JSON lines into a block",
+}
+
+func init() {
+	compressCommand.run = runCompress
+}
+
+func runCompress(args []string, stdio stdio) error {
+	fs := newFlagSet(compressCommand, "[file]", stdio.err)
+	format := fs.String("format", "csv", "input format: csv, tsv or jsonl of [t, v] arrays")
+	output := fs.String("o", "", "output file (default stdout)")
+	header := fs.Uint("header", 0, "block header in Unix seconds (default the first timestamp)")
+	hasHeader := fs.Bool("has-header", false, "skip the header row of csv and tsv")
+	timeFormat := fs.String("time-format", gorilla.TimeUnix, "timestamp format of csv and tsv: unix, unix_ms, unix_us, unix_ns or a Go time layout")
+	timeColumn := fs.Int("time-column", 0, "zero-based timestamp column of csv and tsv")
+	valueColumn := fs.Int("value-column", 1, "zero-based value column of csv and tsv")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if 1<<32-1 < *header {
+		return fmt.Errorf("header is out of range: %d", *header)
+	}
+
+	switch *format {
+	case "csv", "tsv", "jsonl":
+	default:
+		return fmt.Errorf("unknown format: %q", *format)
+	}
+
+	in, err := openInput(fs.Arg(0), stdio.in)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := createOutput(*output, stdio.out)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "csv", "tsv":
+		opts := gorilla.CSVOptions{
+			HasHeader:   *hasHeader,
+			TimeColumn:  *timeColumn,
+			ValueColumn: *valueColumn,
+			TimeFormat:  *timeFormat,
+			BlockHeader: uint32(*header),
+		}
+		if *format == "tsv" {
+			opts.Comma = '\t'
+		}
+		err = gorilla.ImportCSV(out, in, opts)
+	case "jsonl":
+		err = compressJSONLines(out, in, uint32(*header))
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// compressJSONLines compresses lines of [t, v] arrays into a block written to w.
+func compressJSONLines(w io.Writer, r io.Reader, header uint32) error {
+	bw := bufio.NewWriter(w)
+	var c *gorilla.Compressor
+	var finish func() error
+	var last uint32
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var p gorilla.Point
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if c == nil {
+			if header == 0 {
+				header = p.T
+			}
+			var err error
+			if c, finish, err = gorilla.NewCompressor(bw, header); err != nil {
+				return err
+			}
+		} else if p.T < last {
+			return fmt.Errorf("line %d: timestamp %d is before %d", line, p.T, last)
+		}
+		if err := c.Compress(p.T, p.V); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		last = p.T
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if c == nil {
+		return errors.New("no points to compress")
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
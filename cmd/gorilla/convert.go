@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/transcode"
+)
+
+var convertCommand = &command{
+	name:  "convert",
+	usage: "convert a series between gorilla, prometheus and configured wire variants",
+}
+
+func init() {
+	convertCommand.run = runConvert
+}
+
+func runConvert(args []string, stdio stdio) error {
+	fs := newFlagSet(convertCommand, "[file]", stdio.err)
+	from := fs.String("from", "", "variant of the input: gorilla, prometheus or variant")
+	to := fs.String("to", "", "variant of the output: gorilla, prometheus or variant")
+	truncate := fs.Bool("truncate", false, "truncate prometheus timestamps with milliseconds to seconds")
+	unit := fs.Duration("unit", time.Second, "timestamp unit of variant")
+	firstDeltaBits := fs.Int("first-delta-bits", 14, "bit width of the delta of the first timestamp of variant")
+	buckets := fs.String("buckets", "7,9,12,32", "comma-separated delta of delta bucket bit widths of variant")
+	values := fs.String("values", "xor", "value codec of variant: xor or raw")
+	output := fs.String("o", "", "output file (default stdout)")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	variant := transcode.Variant{Encoding: gorilla.Encoding{Unit: *unit, FirstDeltaBits: *firstDeltaBits}}
+	for _, s := range strings.Split(*buckets, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("-buckets: %w", err)
+		}
+		variant.Buckets = append(variant.Buckets, n)
+	}
+	switch *values {
+	case "xor":
+		variant.Values = gorilla.XORValues
+	case "raw":
+		variant.Values = gorilla.RawValues
+	default:
+		return fmt.Errorf("-values: unknown value codec: %q", *values)
+	}
+	src, err := codec(*from, *truncate, variant)
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	dst, err := codec(*to, *truncate, variant)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+
+	in, err := openInput(fs.Arg(0), stdio.in)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	converted, err := transcode.Transcode(b, src, dst)
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(*output, stdio.out)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(converted); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func codec(name string, truncate bool, variant transcode.Variant) (transcode.Codec, error) {
+	switch name {
+	case "gorilla":
+		return transcode.Gorilla, nil
+	case "prometheus":
+		return transcode.Prometheus{Truncate: truncate}, nil
+	case "variant":
+		return variant, nil
+	}
+	return nil, fmt.Errorf("unknown variant: %q", name)
+}
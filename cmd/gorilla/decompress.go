@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/keisku/gorilla"
+)
+
+var decompressCommand = &command{
+	name:  "decompress",
+	usage: "decompress a block into CSV, TSV, JSON or JSON lines",
+}
+
+func init() {
+	decompressCommand.run = runDecompress
+}
+
+func runDecompress(args []string, stdio stdio) error {
+	fs := newFlagSet(decompressCommand, "[file]", stdio.err)
+	format := fs.String("format", "csv", "output format: csv, tsv, json or jsonl")
+	output := fs.String("o", "", "output file (default stdout)")
+	hasHeader := fs.Bool("has-header", false, "write a header row of csv and tsv")
+	timeFormat := fs.String("time-format", gorilla.TimeUnix, "timestamp format of csv and tsv: unix, unix_ms, unix_us, unix_ns or a Go time layout")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	switch *format {
+	case "csv", "tsv", "json", "jsonl":
+	default:
+		return fmt.Errorf("unknown format: %q", *format)
+	}
+
+	in, err := openInput(fs.Arg(0), stdio.in)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	d, _, err := gorilla.NewDecompressor(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(*output, stdio.out)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "csv", "tsv":
+		opts := gorilla.CSVOptions{HasHeader: *hasHeader, TimeFormat: *timeFormat}
+		if *format == "tsv" {
+			opts.Comma = '\t'
+		}
+		err = gorilla.ExportCSV(out, d, opts)
+	case "json":
+		err = gorilla.EncodeJSON(out, d.Iterator())
+	case "jsonl":
+		err = writeJSONLines(out, d.Iterator())
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeJSONLines writes the points of iter as lines of [t, v] arrays.
+func writeJSONLines(w io.Writer, iter *gorilla.DecompressIterator) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for iter.Next() {
+		t, v := iter.At()
+		if err := enc.Encode(gorilla.Point{T: t, V: v}); err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+	return bw.Flush()
+}
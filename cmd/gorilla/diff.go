@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/keisku/gorilla"
+)
+
+var diffCommand = &command{
+	name:  "diff",
+	usage: "compare two blocks point by point, exiting with 1 if they differ",
+}
+
+func init() {
+	diffCommand.run = runDiff
+}
+
+func runDiff(args []string, stdio stdio) error {
+	fs := newFlagSet(diffCommand, "a b", stdio.err)
+	tolerance := fs.Float64("tolerance", 0, "maximum absolute difference of equal values (default bitwise equality)")
+	if err := parseFlags(fs, args, 2); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError(2)
+	}
+
+	var ds [2]*gorilla.Decompressor
+	for i, name := range fs.Args() {
+		in, err := openInput(name, stdio.in)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		if ds[i], _, err = gorilla.NewDecompressor(bufio.NewReader(in)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	diffs, err := gorilla.Diff(ds[0], ds[1], gorilla.DiffOptions{Tolerance: *tolerance})
+	bw := bufio.NewWriter(stdio.out)
+	for _, d := range diffs {
+		fmt.Fprintln(bw, d)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if 0 < len(diffs) {
+		return exitError(1)
+	}
+	return nil
+}
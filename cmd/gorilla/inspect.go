@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+
+	"github.com/keisku/gorilla"
+)
+
+var inspectCommand = &command{
+	name:  "inspect",
+	usage: "print how each point of a block is encoded, bit by bit",
+}
+
+func init() {
+	inspectCommand.run = runInspect
+}
+
+func runInspect(args []string, stdio stdio) error {
+	fs := newFlagSet(inspectCommand, "[file]", stdio.err)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	in, err := openInput(fs.Arg(0), stdio.in)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return gorilla.Dump(stdio.out, bufio.NewReader(in))
+}
@@ -0,0 +1,161 @@
+// Command gorilla creates, examines and repairs gorilla blocks.
+//
+// Usage:
+//
+//	gorilla <command> [flags] [args]
+//
+// The commands are:
+//
+//	compress    compress CSV, TSV or JSON lines into a block
+//	decompress  decompress a block into CSV, TSV, JSON or JSON lines
+//	inspect     dump how each point of a block is encoded
+//	stats       report where the bits of a block go
+//	verify      validate blocks
+//	convert     convert a series between wire variants
+//	plot        draw a block in the terminal
+//	diff        compare the points of two blocks
+//	serve       explore the blocks of a directory in a web browser
+//	repair      recover the points of a damaged block into a new block
+//	bench       measure the compression of synthetic workloads
+//
+// Commands taking a file read it, or stdin if it is omitted or "-", and write
+// to stdout unless -o is given. Run `gorilla <command> -h` for the flags.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdio stdio) error
+}
+
+var commands = []*command{
+	compressCommand,
+	decompressCommand,
+	inspectCommand,
+	statsCommand,
+	verifyCommand,
+	convertCommand,
+	plotCommand,
+	diffCommand,
+	serveCommand,
+	repairCommand,
+	benchCommand,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command of args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(args[1:], stdio{stdin, stdout, stderr})
+		if errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		var exit exitError
+		if errors.As(err, &exit) {
+			return int(exit)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "gorilla %s: %v\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(stderr, "gorilla: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gorilla <command> [flags] [file]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// stdio is the standard streams of a command.
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+}
+
+// exitError is returned by commands to exit with the code after reporting by themselves.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// newFlagSet returns a flag set of the command printing its usage to stderr.
+// args describes the positional arguments in the usage.
+func newFlagSet(cmd *command, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gorilla %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, args, cmd.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args with fs, allowing at most n positional arguments,
+// or any number if n is negative.
+// Errors other than flag.ErrHelp are reported by fs and returned as exitError(2).
+func parseFlags(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return exitError(2)
+	}
+	if 0 <= n && n < fs.NArg() {
+		fmt.Fprintf(fs.Output(), "too many arguments: %q\n", fs.Args()[n:])
+		fs.Usage()
+		return exitError(2)
+	}
+	return nil
+}
+
+// openInput opens the file of name, or returns stdin if name is empty or "-".
+func openInput(name string, stdin io.Reader) (io.ReadCloser, error) {
+	if name == "" || name == "-" {
+		return io.NopCloser(stdin), nil
+	}
+	return os.Open(name)
+}
+
+// createOutput creates the file of name, or returns stdout if name is empty or "-".
+func createOutput(name string, stdout io.Writer) (io.WriteCloser, error) {
+	if name == "" || name == "-" {
+		return nopWriteCloser{stdout}, nil
+	}
+	return os.Create(name)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
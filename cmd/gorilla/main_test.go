@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gorillaCmd runs the command of args with stdin and returns its exit code, stdout and stderr.
+func gorillaCmd(t *testing.T, stdin io.Reader, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, stdin, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func compress(t *testing.T, header uint32, points ...gorilla.Point) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	c, finish, err := gorilla.NewCompressor(buf, header)
+	require.Nil(t, err)
+	for _, p := range points {
+		require.Nil(t, c.Compress(p.T, p.V))
+	}
+	require.Nil(t, finish())
+	return buf.Bytes()
+}
+
+func Test_run_usage(t *testing.T) {
+	code, _, stderr := gorillaCmd(t, nil)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "compress")
+
+	code, _, stderr = gorillaCmd(t, nil, "unknown")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "unknown"`)
+
+	code, _, stderr = gorillaCmd(t, nil, "compress", "-h")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage: gorilla compress")
+
+	code, _, stderr = gorillaCmd(t, nil, "compress", "-unknown")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "flag provided but not defined")
+
+	code, _, _ = gorillaCmd(t, nil, "compress", "a", "b")
+	assert.Equal(t, 2, code)
+}
+
+func Test_compress(t *testing.T) {
+	want := compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2})
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+	}{
+		{"csv", nil, "1600000000,1.5\n1600000060,2\n"},
+		{"tsv", []string{"-format", "tsv", "-has-header"}, "t\tv\n1600000000\t1.5\n1600000060\t2\n"},
+		{"columns", []string{"-time-column", "1", "-value-column", "0", "-time-format", "unix_ms"}, "1.5,1600000000000\n2,1600000060000\n"},
+		{"jsonl", []string{"-format", "jsonl"}, "[1600000000,1.5]\n\n[1600000060,2]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := gorillaCmd(t, strings.NewReader(tt.input), append([]string{"compress"}, tt.args...)...)
+			require.Equal(t, 0, code, stderr)
+			assert.Equal(t, want, []byte(stdout))
+		})
+	}
+
+	code, stdout, stderr := gorillaCmd(t, strings.NewReader("[1600000060,1]\n"), "compress", "-format", "jsonl", "-header", "1600000000")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, compress(t, 1600000000, gorilla.Point{T: 1600000060, V: 1}), []byte(stdout))
+
+	code, stdout, stderr = gorillaCmd(t, strings.NewReader("[1600000060,1]\n[1600000060,2]\n"), "compress", "-format", "jsonl")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, compress(t, 1600000060, gorilla.Point{T: 1600000060, V: 1}, gorilla.Point{T: 1600000060, V: 2}), []byte(stdout))
+
+	code, _, stderr = gorillaCmd(t, strings.NewReader("[1600000060,1]\n"), "compress", "-format", "jsonl", "-header", "1600000120")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "line 1: timestamp out of range: first timestamp 1600000060 is before the header")
+
+	for _, input := range []string{"", "[1600000060,1]\n[1600000000,1]\n", "x\n"} {
+		code, _, stderr = gorillaCmd(t, strings.NewReader(input), "compress", "-format", "jsonl")
+		assert.Equal(t, 1, code)
+		assert.True(t, strings.HasPrefix(stderr, "gorilla compress: "), stderr)
+	}
+}
+
+func Test_decompress(t *testing.T) {
+	block := compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2})
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "1600000000,1.5\n1600000060,2\n"},
+		{[]string{"-format", "tsv", "-has-header", "-time-format", "2006-01-02T15:04:05Z07:00"}, "timestamp\tvalue\n2020-09-13T12:26:40Z\t1.5\n2020-09-13T12:27:40Z\t2\n"},
+		{[]string{"-format", "json"}, "[[1600000000,1.5],[1600000060,2]]"},
+		{[]string{"-format", "jsonl"}, "[1600000000,1.5]\n[1600000060,2]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			code, stdout, stderr := gorillaCmd(t, bytes.NewReader(block), append([]string{"decompress"}, tt.args...)...)
+			require.Equal(t, 0, code, stderr)
+			assert.Equal(t, tt.want, stdout)
+		})
+	}
+
+	code, _, stderr := gorillaCmd(t, bytes.NewReader(block), "decompress", "-format", "xml")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `unknown format: "xml"`)
+
+	code, _, stderr = gorillaCmd(t, bytes.NewReader(block[:len(block)-2]), "decompress")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "truncated block")
+}
+
+func Test_roundTripFiles(t *testing.T) {
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "in.csv")
+	block := filepath.Join(dir, "out.blk")
+	require.Nil(t, os.WriteFile(csv, []byte("1600000000,1.5\n1600000060,NaN\n"), 0o644))
+
+	code, _, stderr := gorillaCmd(t, nil, "compress", "-o", block, csv)
+	require.Equal(t, 0, code, stderr)
+	code, stdout, stderr := gorillaCmd(t, nil, "decompress", block)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "1600000000,1.5\n1600000060,NaN\n", stdout)
+}
+
+func Test_inspect(t *testing.T) {
+	block := compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2})
+	code, stdout, stderr := gorillaCmd(t, bytes.NewReader(block), "inspect")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "finish marker at bit 144")
+	assert.Contains(t, stdout, "points           2")
+
+	code, _, stderr = gorillaCmd(t, bytes.NewReader(block[:len(block)-2]), "inspect")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "truncated block")
+}
+
+func Test_stats(t *testing.T) {
+	block := compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 1.5})
+	code, stdout, stderr := gorillaCmd(t, bytes.NewReader(block), "stats")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "bytes/point")
+
+	code, stdout, stderr = gorillaCmd(t, bytes.NewReader(block), "stats", "-json")
+	require.Equal(t, 0, code, stderr)
+	var a gorilla.Analysis
+	require.Nil(t, json.Unmarshal([]byte(stdout), &a))
+	assert.Equal(t, 2, a.Points)
+	assert.Equal(t, 1, a.IdenticalValues)
+}
+
+func Test_verify(t *testing.T) {
+	dir := t.TempDir()
+	block := compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 1.5})
+	ok := filepath.Join(dir, "ok.blk")
+	truncated := filepath.Join(dir, "truncated.blk")
+	require.Nil(t, os.WriteFile(ok, block, 0o644))
+	require.Nil(t, os.WriteFile(truncated, block[:len(block)-2], 0o644))
+
+	code, stdout, stderr := gorillaCmd(t, nil, "verify", ok)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, ok+": ok: header 1600000000, 2 points in [1600000000, 1600000060], 20 bytes\n", stdout)
+
+	code, stdout, _ = gorillaCmd(t, nil, "verify", ok, truncated)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, truncated+": bit 120: missing finish marker\n")
+
+	code, stdout, _ = gorillaCmd(t, nil, "verify", "-json", truncated)
+	assert.Equal(t, 1, code)
+	var report struct {
+		File    string
+		Problem string
+		Offset  uint64
+	}
+	require.Nil(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, truncated, report.File)
+	assert.Equal(t, "missing finish marker", report.Problem)
+
+	code, _, stderr = gorillaCmd(t, nil, "verify", filepath.Join(dir, "missing.blk"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "no such file")
+}
+
+func Test_bench(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "bench.json")
+	code, stdout, stderr := gorillaCmd(t, nil, "bench", "-points", "100", "-duration", "1ms", "-o", output)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "random-walk")
+
+	b, err := os.ReadFile(output)
+	require.Nil(t, err)
+	var results []benchResult
+	require.Nil(t, json.Unmarshal(b, &results))
+	require.Len(t, results, 6)
+	assert.Equal(t, "constant", results[0].Workload)
+	assert.Equal(t, 100, results[0].Points)
+	assert.Less(t, results[0].BytesPerPoint, results[len(results)-1].BytesPerPoint)
+	assert.Less(t, 0.0, results[0].CompressRate)
+}
+
+func Test_convert(t *testing.T) {
+	block := compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2})
+	code, chunk, stderr := gorillaCmd(t, bytes.NewReader(block), "convert", "--from", "gorilla", "--to", "prometheus")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, []byte{0, 2}, []byte(chunk)[:2], "chunks start with the number of samples")
+
+	code, stdout, stderr := gorillaCmd(t, strings.NewReader(chunk), "convert", "-from", "prometheus", "-to", "gorilla")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, block, []byte(stdout))
+
+	code, _, stderr = gorillaCmd(t, bytes.NewReader(block), "convert", "-from", "gorilla", "-to", "influx")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `-to: unknown variant: "influx"`)
+
+	code, series, stderr := gorillaCmd(t, bytes.NewReader(block), "convert", "-from", "gorilla", "-to", "variant", "-unit", "1ms", "-buckets", "14,17,20,64")
+	require.Equal(t, 0, code, stderr)
+	code, stdout, stderr = gorillaCmd(t, strings.NewReader(series), "convert", "-from", "variant", "-to", "gorilla", "-unit", "1ms", "-buckets", "14,17,20,64")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, block, []byte(stdout))
+
+	code, series, stderr = gorillaCmd(t, bytes.NewReader(block), "convert", "-from", "gorilla", "-to", "variant", "-values", "raw")
+	require.Equal(t, 0, code, stderr)
+	code, stdout, stderr = gorillaCmd(t, strings.NewReader(series), "convert", "-from", "variant", "-to", "gorilla", "-values", "raw")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, block, []byte(stdout))
+
+	code, _, stderr = gorillaCmd(t, bytes.NewReader(block), "convert", "-from", "gorilla", "-to", "variant", "-buckets", "7,x")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-buckets:")
+	code, _, stderr = gorillaCmd(t, bytes.NewReader(block), "convert", "-from", "gorilla", "-to", "variant", "-values", "zstd")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `-values: unknown value codec: "zstd"`)
+}
+
+func Test_plot(t *testing.T) {
+	var points []gorilla.Point
+	for i := 0; i < 7200; i++ {
+		points = append(points, gorilla.Point{T: 1600000000 + uint32(i), V: float64(i % 100)})
+	}
+	points = append(points, gorilla.Point{T: 1600007200, V: math.NaN()})
+	block := compress(t, 1600000000, points...)
+
+	code, stdout, stderr := gorillaCmd(t, bytes.NewReader(block), "plot", "-width", "40", "-height", "5")
+	require.Equal(t, 0, code, stderr)
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	require.Len(t, lines, 7)
+	assert.Equal(t, "99 ┤││││││││││││││││││││││││││││││││││││││││", strings.TrimSpace(lines[0]))
+	assert.Equal(t, "49.5 ┤••••••••••••••••••••••••••••••••••••••••", strings.TrimSpace(lines[2]))
+	assert.Equal(t, "0 ┤││││││││││││││││││││││││││││││││││││││││", strings.TrimSpace(lines[4]))
+	assert.Equal(t, "2020-09-13T12:26:40Z 2020-09-13T14:26:39Z", strings.Join(strings.Fields(lines[6]), " "))
+
+	code, stdout, stderr = gorillaCmd(t, bytes.NewReader(block), "plot", "-width", "10", "-height", "3", "-from", "1600000000", "-to", "1600000009")
+	require.Equal(t, 0, code, stderr)
+	lines = strings.Split(stdout, "\n")
+	assert.Equal(t, "  9 ┤       •••", lines[0])
+	assert.Equal(t, "4.5 ┤   ••••", lines[1])
+	assert.Equal(t, "  0 ┤•••", lines[2])
+
+	code, _, stderr = gorillaCmd(t, bytes.NewReader(block), "plot", "-from", "1700000000")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "no points to plot")
+
+	unordered := compress(t, 1600000000,
+		gorilla.Point{T: 1600000100, V: 1},
+		gorilla.Point{T: 1600000050, V: 2},
+		gorilla.Point{T: 1600000200, V: 3},
+	)
+	code, stdout, stderr = gorillaCmd(t, bytes.NewReader(unordered), "plot", "-width", "3", "-height", "3")
+	require.Equal(t, 0, code, stderr)
+	lines = strings.Split(stdout, "\n")
+	assert.Equal(t, "3 ┤  •", lines[0])
+	assert.Equal(t, "2 ┤│·", lines[1])
+	assert.Equal(t, "1 ┤•", lines[2])
+	assert.Equal(t, "2020-09-13T12:27:30Z 2020-09-13T12:30:00Z", strings.Join(strings.Fields(lines[4]), " "))
+}
+
+func Test_diff(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.blk")
+	b := filepath.Join(dir, "b.blk")
+	require.Nil(t, os.WriteFile(a, compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2}), 0o644))
+	require.Nil(t, os.WriteFile(b, compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2.001}, gorilla.Point{T: 1600000120, V: 3}), 0o644))
+
+	code, stdout, stderr := gorillaCmd(t, nil, "diff", a, a)
+	require.Equal(t, 0, code, stderr)
+	assert.Empty(t, stdout)
+
+	code, stdout, _ = gorillaCmd(t, nil, "diff", a, b)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "~ 1600000060 2 -> 2.001\n+ 1600000120 3\n", stdout)
+
+	code, stdout, _ = gorillaCmd(t, nil, "diff", "-tolerance", "0.01", a, b)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "+ 1600000120 3\n", stdout)
+
+	code, _, _ = gorillaCmd(t, nil, "diff", a)
+	assert.Equal(t, 2, code)
+}
+
+func Test_serve(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "a.blk"), compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2}, gorilla.Point{T: 1600000120, V: 3}), 0o644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("not a block"), 0o644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0o644))
+	srv := httptest.NewServer(newExplorer(dir))
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	code, body := get("/api/blocks")
+	require.Equal(t, http.StatusOK, code, body)
+	var blocks []map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(body), &blocks))
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "a.blk", blocks[0]["name"])
+	assert.Equal(t, float64(3), blocks[0]["count"])
+	assert.Equal(t, float64(1600000000), blocks[0]["minTime"])
+	assert.Equal(t, float64(1600000120), blocks[0]["maxTime"])
+	assert.NotContains(t, blocks[0], "error")
+	assert.Equal(t, "b.txt", blocks[1]["name"])
+	assert.NotEmpty(t, blocks[1]["error"])
+
+	code, body = get("/api/blocks/a.blk?from=1600000030&to=1600000120")
+	require.Equal(t, http.StatusOK, code, body)
+	assert.JSONEq(t, "[[1600000060,2],[1600000120,3]]", body)
+
+	code, body = get("/api/blocks/a.blk")
+	require.Equal(t, http.StatusOK, code, body)
+	assert.JSONEq(t, "[[1600000000,1.5],[1600000060,2],[1600000120,3]]", body)
+
+	code, _ = get("/api/blocks/a.blk?from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("/api/blocks/b.txt")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	code, _ = get("/api/blocks/missing.blk")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get("/api/blocks/..%2Fa.blk")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get("/api/blocks/.hidden")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = get("/")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "<html")
+
+	resp, err := http.Post(srv.URL+"/api/blocks", "application/json", nil)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func Test_repair(t *testing.T) {
+	b := compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2}, gorilla.Point{T: 1600000120, V: 3})
+	want := compress(t, 1600000000, gorilla.Point{T: 1600000000, V: 1.5}, gorilla.Point{T: 1600000060, V: 2})
+
+	code, stdout, stderr := gorillaCmd(t, bytes.NewReader(b[:18]), "repair")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, string(want), stdout)
+	assert.Contains(t, stderr, "recovered 2 points, lost")
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.blk")
+	out := filepath.Join(dir, "out.blk")
+	require.Nil(t, os.WriteFile(in, b, 0o644))
+	code, stdout, stderr = gorillaCmd(t, nil, "repair", "-o", out, in)
+	require.Equal(t, 0, code, stderr)
+	assert.Empty(t, stdout)
+	assert.Equal(t, "intact: 3 points\n", stderr)
+	got, err := os.ReadFile(out)
+	require.Nil(t, err)
+	assert.Equal(t, b, got)
+
+	code, _, stderr = gorillaCmd(t, bytes.NewReader(b[:2]), "repair")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "truncated block")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/keisku/gorilla"
+)
+
+var plotCommand = &command{
+	name:  "plot",
+	usage: "draw a series of a block as a chart in the terminal",
+}
+
+func init() {
+	plotCommand.run = runPlot
+}
+
+func runPlot(args []string, stdio stdio) error {
+	fs := newFlagSet(plotCommand, "[file]", stdio.err)
+	width := fs.Int("width", 80, "width of the chart in columns, excluding the axis")
+	height := fs.Int("height", 20, "height of the chart in rows")
+	from := fs.Uint("from", 0, "start of the time range in Unix seconds (default the first point)")
+	to := fs.Uint("to", 0, "end of the time range in Unix seconds (default the last point)")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if *width < 2 || *height < 2 {
+		return errors.New("width and height must be at least 2")
+	}
+
+	in, err := openInput(fs.Arg(0), stdio.in)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	d, _, err := gorilla.NewDecompressor(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+	var points []gorilla.Point
+	iter := d.Iterator()
+	for iter.Next() {
+		t, v := iter.At()
+		if uint(t) < *from || 0 < *to && *to < uint(t) {
+			continue
+		}
+		// NaN and infinities cannot be drawn.
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		points = append(points, gorilla.Point{T: t, V: v})
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+	if len(points) == 0 {
+		return errors.New("no points to plot")
+	}
+
+	// Blocks may hold points out of time order, so the range spans the
+	// earliest to the latest point.
+	start, end := points[0].T, points[0].T
+	for _, p := range points[1:] {
+		if p.T < start {
+			start = p.T
+		}
+		if end < p.T {
+			end = p.T
+		}
+	}
+	if *from != 0 {
+		start = uint32(*from)
+	}
+	if *to != 0 {
+		end = uint32(*to)
+	}
+	bw := bufio.NewWriter(stdio.out)
+	plot(bw, columns(points, start, end, *width), *height, start, end)
+	return bw.Flush()
+}
+
+// column aggregates the points drawn in a column.
+type column struct {
+	min, max, sum float64
+	n             int
+}
+
+func (c column) avg() float64 {
+	return c.sum / float64(c.n)
+}
+
+// columns downsamples points in [start, end] into width columns of equal duration.
+func columns(points []gorilla.Point, start, end uint32, width int) []column {
+	cols := make([]column, width)
+	span := uint64(end-start) + 1
+	for _, p := range points {
+		c := &cols[uint64(p.T-start)*uint64(width)/span]
+		if c.n == 0 || p.V < c.min {
+			c.min = p.V
+		}
+		if c.n == 0 || c.max < p.V {
+			c.max = p.V
+		}
+		c.sum += p.V
+		c.n++
+	}
+	return cols
+}
+
+// plot draws the range of each column with '│', its average with '•', and
+// connects the averages of adjacent columns with '·'.
+func plot(w io.Writer, cols []column, height int, start, end uint32) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, c := range cols {
+		if 0 < c.n {
+			lo, hi = math.Min(lo, c.min), math.Max(hi, c.max)
+		}
+	}
+	if lo == hi {
+		lo, hi = lo-1, hi+1
+	}
+	row := func(v float64) int {
+		return int(math.Round((hi - v) / (hi - lo) * float64(height-1)))
+	}
+
+	grid := make([][]rune, height)
+	for i := range grid {
+		grid[i] = []rune(strings.Repeat(" ", len(cols)))
+	}
+	prev := -1
+	for x, c := range cols {
+		if c.n == 0 {
+			continue
+		}
+		for y := row(c.max); y <= row(c.min); y++ {
+			grid[y][x] = '│'
+		}
+		avg := row(c.avg())
+		if 0 <= prev {
+			for y := minInt(prev, avg) + 1; y < maxInt(prev, avg); y++ {
+				if grid[y][x-1] == ' ' {
+					grid[y][x-1] = '·'
+				}
+			}
+		}
+		grid[avg][x] = '•'
+		prev = avg
+	}
+
+	labels := make([]string, height)
+	labelWidth := 0
+	for y := range labels {
+		if y == 0 || y == height-1 || y == height/2 {
+			labels[y] = fmt.Sprintf("%.6g", hi-(hi-lo)*float64(y)/float64(height-1))
+		}
+		labelWidth = maxInt(labelWidth, len(labels[y]))
+	}
+	for y, line := range grid {
+		tick := '│'
+		if labels[y] != "" {
+			tick = '┤'
+		}
+		fmt.Fprintf(w, "%*s %c%s\n", labelWidth, labels[y], tick, strings.TrimRight(string(line), " "))
+	}
+	fmt.Fprintf(w, "%*s └%s\n", labelWidth, "", strings.Repeat("─", len(cols)))
+	first, last := formatUnix(start), formatUnix(end)
+	gap := len(cols) - len(first) - len(last)
+	if gap < 1 {
+		gap = 1
+	}
+	fmt.Fprintf(w, "%*s  %s%s%s\n", labelWidth, "", first, strings.Repeat(" ", gap), last)
+}
+
+func formatUnix(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format(time.RFC3339)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a < b {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/keisku/gorilla"
+)
+
+var repairCommand = &command{
+	name:  "repair",
+	usage: "recover the points of a truncated or corrupted block into a new block",
+}
+
+func init() {
+	repairCommand.run = runRepair
+}
+
+func runRepair(args []string, stdio stdio) error {
+	fs := newFlagSet(repairCommand, "[file]", stdio.err)
+	output := fs.String("o", "", "output file (default stdout)")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	in, err := openInput(fs.Arg(0), stdio.in)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	repaired, report, err := gorilla.Repair(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+	// The report goes to stderr not to mix with the block written to stdout.
+	fmt.Fprintln(stdio.err, report)
+
+	out, err := createOutput(*output, stdio.out)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(repaired); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keisku/gorilla"
+)
+
+var serveCommand = &command{
+	name:  "serve",
+	usage: "serve a web UI and JSON API exploring the blocks of a directory",
+}
+
+func init() {
+	serveCommand.run = runServe
+}
+
+//go:embed ui
+var ui embed.FS
+
+func runServe(args []string, stdio stdio) error {
+	fs := newFlagSet(serveCommand, "dir", stdio.err)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError(2)
+	}
+	dir := fs.Arg(0)
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdio.err, "serving %s on http://%s\n", dir, l.Addr())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return serve(ctx, l, newExplorer(dir))
+}
+
+// serve serves h on l until ctx is done.
+func serve(ctx context.Context, l net.Listener, h http.Handler) error {
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// explorer serves the blocks of dir:
+//
+//	GET /                               the web UI
+//	GET /api/blocks                     the files of dir with their stats
+//	GET /api/blocks/{name}?from=&to=    the points of a file in [from, to] as [[t, v], ...]
+type explorer struct {
+	dir string
+	mux *http.ServeMux
+}
+
+func newExplorer(dir string) *explorer {
+	e := &explorer{dir: dir, mux: http.NewServeMux()}
+	static, err := fs.Sub(ui, "ui")
+	if err != nil {
+		panic(err)
+	}
+	e.mux.Handle("/", http.FileServer(http.FS(static)))
+	e.mux.HandleFunc("/api/blocks", e.listBlocks)
+	e.mux.HandleFunc("/api/blocks/", e.getPoints)
+	return e
+}
+
+func (e *explorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	e.mux.ServeHTTP(w, r)
+}
+
+type blockInfo struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	gorilla.BlockStats
+	// Error is why the file is not a valid block.
+	Error string `json:"error,omitempty"`
+}
+
+func (e *explorer) listBlocks(w http.ResponseWriter, r *http.Request) {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	blocks := []blockInfo{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(e.dir, entry.Name()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		info := blockInfo{Name: entry.Name(), Size: int64(len(b))}
+		if info.BlockStats, err = gorilla.Block(b).Stats(); err != nil {
+			info.Error = err.Error()
+		}
+		blocks = append(blocks, info)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Name < blocks[j].Name })
+	writeJSON(w, blocks)
+}
+
+func (e *explorer) getPoints(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/blocks/")
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		http.NotFound(w, r)
+		return
+	}
+	from, err := parseTime(r.URL.Query().Get("from"), 0)
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTime(r.URL.Query().Get("to"), 1<<32-1)
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b, err := os.ReadFile(filepath.Join(e.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := gorilla.Block(b).Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	d, err := gorilla.Block(b).Decompressor()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	points := []gorilla.Point{}
+	iter := d.Iterator()
+	for iter.Next() {
+		t, v := iter.At()
+		if from <= t && t <= to {
+			points = append(points, gorilla.Point{T: t, V: v})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, points)
+}
+
+func parseTime(s string, def uint32) (uint32, error) {
+	if s == "" {
+		return def, nil
+	}
+	t, err := strconv.ParseUint(s, 10, 32)
+	return uint32(t), err
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
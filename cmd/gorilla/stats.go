@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+
+	"github.com/keisku/gorilla"
+)
+
+var statsCommand = &command{
+	name:  "stats",
+	usage: "report bytes per point and how timestamps and values are encoded",
+}
+
+func init() {
+	statsCommand.run = runStats
+}
+
+func runStats(args []string, stdio stdio) error {
+	fs := newFlagSet(statsCommand, "[file]", stdio.err)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	in, err := openInput(fs.Arg(0), stdio.in)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	a, err := gorilla.Analyze(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return json.NewEncoder(stdio.out).Encode(a)
+	}
+	_, err = a.WriteTo(stdio.out)
+	return err
+}
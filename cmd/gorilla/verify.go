@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/keisku/gorilla"
+)
+
+var verifyCommand = &command{
+	name:  "verify",
+	usage: "validate blocks, exiting with 1 if any is corrupted",
+}
+
+func init() {
+	verifyCommand.run = runVerify
+}
+
+func runVerify(args []string, stdio stdio) error {
+	fs := newFlagSet(verifyCommand, "[file...]", stdio.err)
+	asJSON := fs.Bool("json", false, "print a JSON report per line")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	corrupted := 0
+	enc := json.NewEncoder(stdio.out)
+	for _, file := range files {
+		report, err := verifyFile(file, stdio)
+		if err != nil && !errors.Is(err, gorilla.ErrCorruptBlock) {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if !report.OK() {
+			corrupted++
+		}
+		if *asJSON {
+			if err := enc.Encode(struct {
+				File string `json:"file"`
+				gorilla.Report
+			}{file, report}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(stdio.out, "%s: %s\n", file, report)
+	}
+	if 0 < corrupted {
+		return exitError(1)
+	}
+	return nil
+}
+
+func verifyFile(name string, stdio stdio) (gorilla.Report, error) {
+	in, err := openInput(name, stdio.in)
+	if err != nil {
+		return gorilla.Report{}, err
+	}
+	defer in.Close()
+	return gorilla.Verify(bufio.NewReader(in))
+}
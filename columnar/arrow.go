@@ -0,0 +1,138 @@
+package columnar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/keisku/gorilla"
+)
+
+// Column names of Schema.
+const (
+	TimestampColumn = "timestamp"
+	ValueColumn     = "value"
+)
+
+// Schema is the schema of records decoded from blocks: a non-nullable timestamp
+// column in seconds, which is the precision of blocks, and a non-nullable
+// float64 column.
+var Schema = arrow.NewSchema([]arrow.Field{
+	{Name: TimestampColumn, Type: &arrow.TimestampType{Unit: arrow.Second, TimeZone: "UTC"}},
+	{Name: ValueColumn, Type: arrow.PrimitiveTypes.Float64},
+}, nil)
+
+// Record decodes block into a record of Schema allocated by mem.
+// The record must be released by the caller.
+func Record(mem memory.Allocator, block []byte) (arrow.Record, error) {
+	d, _, err := gorilla.NewDecompressor(bytes.NewReader(block))
+	if err != nil {
+		return nil, err
+	}
+	b := array.NewRecordBuilder(mem, Schema)
+	defer b.Release()
+	ts := b.Field(0).(*array.TimestampBuilder)
+	vs := b.Field(1).(*array.Float64Builder)
+	iter := d.Iterator()
+	for iter.Next() {
+		t, v := iter.At()
+		ts.Append(arrow.Timestamp(t))
+		vs.Append(v)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	return b.NewRecord(), nil
+}
+
+// Records decodes each of blocks into a record of Schema allocated by mem,
+// which can be read as batches by array.NewRecordReader(Schema, records).
+// The records must be released by the caller.
+func Records(mem memory.Allocator, blocks ...[]byte) ([]arrow.Record, error) {
+	records := make([]arrow.Record, 0, len(blocks))
+	for i, block := range blocks {
+		rec, err := Record(mem, block)
+		if err != nil {
+			for _, rec := range records {
+				rec.Release()
+			}
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// Compress compresses the points of a timestamp column and a float64 column
+// of the same length into a block whose header is the first timestamp.
+// Timestamps of any unit are truncated to seconds and must not decrease after
+// that. Rows with a null timestamp or value are skipped.
+func Compress(timestamps *array.Timestamp, values *array.Float64) ([]byte, error) {
+	if timestamps.Len() != values.Len() {
+		return nil, fmt.Errorf("column lengths differ: %d timestamps and %d values", timestamps.Len(), values.Len())
+	}
+	// Every Arrow time unit divides a second, so dividing by the units in a
+	// second truncates without overflowing.
+	perSecond := int64(time.Second / timestamps.DataType().(*arrow.TimestampType).Unit.Multiplier())
+	ts := timestamps.TimestampValues()
+	vs := values.Float64Values()
+
+	buf := new(bytes.Buffer)
+	var c *gorilla.Compressor
+	var finish func() error
+	var last uint32
+	for i := range ts {
+		if timestamps.IsNull(i) || values.IsNull(i) {
+			continue
+		}
+		t, err := gorilla.UnixSeconds(int64(ts[i]) / perSecond)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		if c == nil {
+			if c, finish, err = gorilla.NewCompressor(buf, t); err != nil {
+				return nil, err
+			}
+		} else if t < last {
+			return nil, fmt.Errorf("row %d: timestamp %d is before %d", i, t, last)
+		}
+		if err := c.Compress(t, vs[i]); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		last = t
+	}
+	if c == nil {
+		return nil, errors.New("no rows to compress")
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CompressRecord compresses the TimestampColumn and ValueColumn columns of rec
+// into a block as Compress does.
+func CompressRecord(rec arrow.Record) ([]byte, error) {
+	var timestamps *array.Timestamp
+	var values *array.Float64
+	for i, f := range rec.Schema().Fields() {
+		switch col := rec.Column(i).(type) {
+		case *array.Timestamp:
+			if f.Name == TimestampColumn {
+				timestamps = col
+			}
+		case *array.Float64:
+			if f.Name == ValueColumn {
+				values = col
+			}
+		}
+	}
+	if timestamps == nil || values == nil {
+		return nil, fmt.Errorf("record has no timestamp column %q and float64 column %q", TimestampColumn, ValueColumn)
+	}
+	return Compress(timestamps, values)
+}
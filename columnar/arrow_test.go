@@ -0,0 +1,121 @@
+package columnar_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/columnar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, header uint32, ts []uint32, vs []float64) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	c, finish, err := gorilla.NewCompressor(buf, header)
+	require.Nil(t, err)
+	for i := range ts {
+		require.Nil(t, c.Compress(ts[i], vs[i]))
+	}
+	require.Nil(t, finish())
+	return buf.Bytes()
+}
+
+func Test_Records(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	blocks := [][]byte{
+		compress(t, 1600000000, []uint32{1600000000, 1600000060}, []float64{1.5, math.NaN()}),
+		compress(t, 1600007200, []uint32{1600007200}, []float64{3}),
+	}
+	records, err := columnar.Records(mem, blocks...)
+	require.Nil(t, err)
+	require.Len(t, records, 2)
+
+	rec := records[0]
+	assert.True(t, rec.Schema().Equal(columnar.Schema))
+	assert.Equal(t, int64(2), rec.NumRows())
+	assert.Equal(t, []arrow.Timestamp{1600000000, 1600000060}, rec.Column(0).(*array.Timestamp).TimestampValues())
+	vs := rec.Column(1).(*array.Float64).Float64Values()
+	assert.Equal(t, 1.5, vs[0])
+	assert.True(t, math.IsNaN(vs[1]))
+	assert.Equal(t, int64(1), records[1].NumRows())
+
+	for i, rec := range records {
+		b, err := columnar.CompressRecord(rec)
+		require.Nil(t, err)
+		assert.Equal(t, blocks[i], b)
+		rec.Release()
+	}
+
+	_, err = columnar.Records(mem, blocks[0], []byte{0x01})
+	assert.NotNil(t, err)
+}
+
+func Test_Compress(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	tb := array.NewTimestampBuilder(mem, &arrow.TimestampType{Unit: arrow.Millisecond})
+	defer tb.Release()
+	tb.AppendValues([]arrow.Timestamp{1600000000500, 1600000060000, 1600000120000}, nil)
+	tb.AppendNull()
+	tb.Append(1600000060900)
+	timestamps := tb.NewTimestampArray()
+	defer timestamps.Release()
+
+	vb := array.NewFloat64Builder(mem)
+	defer vb.Release()
+	vb.AppendValues([]float64{1, 2, 0, 4, 5}, []bool{true, true, false, true, true})
+	values := vb.NewFloat64Array()
+	defer values.Release()
+
+	b, err := columnar.Compress(timestamps, values)
+	require.Nil(t, err)
+	assert.Equal(t, compress(t, 1600000000, []uint32{1600000000, 1600000060, 1600000060}, []float64{1, 2, 5}), b)
+
+	short := array.NewSlice(values, 0, 1).(*array.Float64)
+	defer short.Release()
+	_, err = columnar.Compress(timestamps, short)
+	assert.NotNil(t, err)
+}
+
+func Test_Compress_error(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	tests := []struct {
+		name string
+		ts   []arrow.Timestamp
+		want string
+	}{
+		{"empty", nil, "no rows to compress"},
+		{"zero", []arrow.Timestamp{0}, "row 0: timestamp out of range: 0"},
+		{"decreasing", []arrow.Timestamp{1600000060, 1600000000}, "row 1: timestamp 1600000000 is before 1600000060"},
+		{"overflow", []arrow.Timestamp{1600000000, 10000000000}, "row 1: timestamp out of range: 10000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := array.NewTimestampBuilder(mem, &arrow.TimestampType{Unit: arrow.Second})
+			defer tb.Release()
+			tb.AppendValues(tt.ts, nil)
+			timestamps := tb.NewTimestampArray()
+			defer timestamps.Release()
+			vb := array.NewFloat64Builder(mem)
+			defer vb.Release()
+			vb.AppendValues(make([]float64, len(tt.ts)), nil)
+			values := vb.NewFloat64Array()
+			defer values.Release()
+
+			_, err := columnar.Compress(timestamps, values)
+			require.NotNil(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
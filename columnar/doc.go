@@ -0,0 +1,2 @@
+// Package columnar converts gorilla blocks to and from Apache Arrow records.
+package columnar
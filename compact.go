@@ -0,0 +1,141 @@
+package gorilla
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrOverlap is returned by CompactWith with RejectOverlap when blocks have points at the same timestamp.
+var ErrOverlap = errors.New("overlapping points")
+
+// OverlapPolicy decides which point is kept when compacted blocks have points at the same timestamp.
+type OverlapPolicy int
+
+const (
+	// KeepLast keeps the point of the block passed last.
+	KeepLast OverlapPolicy = iota
+	// KeepFirst keeps the point of the block passed first.
+	KeepFirst
+	// RejectOverlap fails the compaction with ErrOverlap.
+	RejectOverlap
+)
+
+// CompactOptions configures CompactWith.
+type CompactOptions struct {
+	Overlap OverlapPolicy
+}
+
+// CompactStats describes the result of a compaction.
+type CompactStats struct {
+	InputBytes  int
+	OutputBytes int
+	Points      int
+	// Dropped is the number of points discarded by the overlap policy.
+	Dropped int
+}
+
+// Saved returns the number of bytes saved by the compaction.
+func (s CompactStats) Saved() int {
+	return s.InputBytes - s.OutputBytes
+}
+
+// Compact merges blocks of the same series into one block, keeping the point
+// of the block passed last on overlapping timestamps.
+func Compact(blocks ...[]byte) ([]byte, error) {
+	b, _, err := CompactWith(CompactOptions{}, blocks...)
+	return b, err
+}
+
+// CompactWith merges blocks of the same series into one block ordered by timestamp,
+// resolving timestamps shared by points of different blocks with opts.Overlap.
+// Points of a single block at the same timestamp are all kept. The header of the new block
+// is the earliest header of blocks, or the first timestamp if it is too far
+// from the earliest header to be encoded.
+func CompactWith(opts CompactOptions, blocks ...[]byte) ([]byte, CompactStats, error) {
+	var stats CompactStats
+	if len(blocks) == 0 {
+		return nil, stats, errors.New("no blocks to compact")
+	}
+
+	type point struct {
+		t     uint32
+		v     float64
+		block int
+	}
+	var points []point
+	var header uint32
+	for i, b := range blocks {
+		stats.InputBytes += len(b)
+		d, h, err := NewDecompressor(bytes.NewReader(b))
+		if err != nil {
+			return nil, stats, fmt.Errorf("failed to open block %d: %w", i, err)
+		}
+		if i == 0 || h < header {
+			header = h
+		}
+		iter := d.Iterator()
+		for iter.Next() {
+			t, v := iter.At()
+			points = append(points, point{t, v, i})
+		}
+		if err := iter.Err(); err != nil {
+			return nil, stats, fmt.Errorf("failed to decompress block %d: %w", i, err)
+		}
+	}
+
+	// The stable sort keeps the points at the same timestamp in the order of blocks.
+	sort.SliceStable(points, func(i, j int) bool { return points[i].t < points[j].t })
+	merged := make([]point, 0, len(points))
+	for i := 0; i < len(points); {
+		j := i + 1
+		for j < len(points) && points[j].t == points[i].t {
+			j++
+		}
+		group := points[i:j]
+		i = j
+		first, last := group[0].block, group[len(group)-1].block
+		if first == last {
+			merged = append(merged, group...)
+			continue
+		}
+		keep := last
+		switch opts.Overlap {
+		case KeepLast:
+		case KeepFirst:
+			keep = first
+		case RejectOverlap:
+			return nil, stats, fmt.Errorf("%w at %d", ErrOverlap, group[0].t)
+		default:
+			return nil, stats, fmt.Errorf("unknown overlap policy: %d", opts.Overlap)
+		}
+		for _, p := range group {
+			if p.block != keep {
+				stats.Dropped++
+				continue
+			}
+			merged = append(merged, p)
+		}
+	}
+	if 0 < len(merged) && 1<<firstDeltaBits-1 <= merged[0].t-header {
+		header = merged[0].t
+	}
+
+	buf := new(bytes.Buffer)
+	c, finish, err := NewCompressor(buf, header)
+	if err != nil {
+		return nil, stats, err
+	}
+	for _, p := range merged {
+		if err := c.Compress(p.t, p.v); err != nil {
+			return nil, stats, err
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, stats, fmt.Errorf("failed to finish block: %w", err)
+	}
+	stats.Points = len(merged)
+	stats.OutputBytes = buf.Len()
+	return buf.Bytes(), stats, nil
+}
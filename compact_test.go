@@ -0,0 +1,148 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type point struct {
+	t uint32
+	v float64
+}
+
+func compress(t *testing.T, header uint32, points []point) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	c, finish, err := gorilla.NewCompressor(buf, header)
+	require.Nil(t, err)
+	for _, p := range points {
+		require.Nil(t, c.Compress(p.t, p.v))
+	}
+	require.Nil(t, finish())
+	return buf.Bytes()
+}
+
+func decompress(t *testing.T, b []byte) (uint32, []point) {
+	t.Helper()
+	d, header, err := gorilla.NewDecompressor(bytes.NewReader(b))
+	require.Nil(t, err)
+	var points []point
+	iter := d.Iterator()
+	for iter.Next() {
+		ts, v := iter.At()
+		points = append(points, point{ts, v})
+	}
+	require.Nil(t, iter.Err())
+	return header, points
+}
+
+func series(start uint32, n int, step uint32, v float64) []point {
+	points := make([]point, n)
+	for i := range points {
+		points[i] = point{start + uint32(i)*step, v + float64(i)}
+	}
+	return points
+}
+
+func Test_Compact(t *testing.T) {
+	const header = uint32(1600000000)
+	first := series(header, 60, 60, 0)
+	second := series(header+3600, 60, 60, 60)
+	third := series(header+7200, 60, 60, 120)
+
+	b, stats, err := gorilla.CompactWith(gorilla.CompactOptions{},
+		compress(t, header, first), compress(t, header+3600, second), compress(t, header+7200, third))
+	require.Nil(t, err)
+
+	h, points := decompress(t, b)
+	assert.Equal(t, header, h)
+	assert.Equal(t, append(append(first, second...), third...), points)
+	assert.Equal(t, 180, stats.Points)
+	assert.Equal(t, 0, stats.Dropped)
+	assert.Equal(t, len(b), stats.OutputBytes)
+	assert.Greater(t, stats.Saved(), 0)
+}
+
+func Test_Compact_Overlap(t *testing.T) {
+	const header = uint32(1600000000)
+	first := compress(t, header, []point{{header, 1}, {header + 60, 2}, {header + 120, 3}})
+	second := compress(t, header+60, []point{{header + 60, 20}, {header + 120, 30}, {header + 180, 40}})
+
+	tests := []struct {
+		name   string
+		policy gorilla.OverlapPolicy
+		want   []point
+	}{
+		{
+			name:   "keep last",
+			policy: gorilla.KeepLast,
+			want:   []point{{header, 1}, {header + 60, 20}, {header + 120, 30}, {header + 180, 40}},
+		},
+		{
+			name:   "keep first",
+			policy: gorilla.KeepFirst,
+			want:   []point{{header, 1}, {header + 60, 2}, {header + 120, 3}, {header + 180, 40}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, stats, err := gorilla.CompactWith(gorilla.CompactOptions{Overlap: tt.policy}, first, second)
+			require.Nil(t, err)
+			_, points := decompress(t, b)
+			assert.Equal(t, tt.want, points)
+			assert.Equal(t, 2, stats.Dropped)
+		})
+	}
+
+	_, _, err := gorilla.CompactWith(gorilla.CompactOptions{Overlap: gorilla.RejectOverlap}, first, second)
+	assert.True(t, errors.Is(err, gorilla.ErrOverlap))
+
+	b, err := gorilla.Compact(second, first)
+	require.Nil(t, err)
+	_, points := decompress(t, b)
+	assert.Equal(t, []point{{header, 1}, {header + 60, 2}, {header + 120, 3}, {header + 180, 40}}, points)
+}
+
+func Test_Compact_RepeatedTimestamp(t *testing.T) {
+	const header = uint32(1600000000)
+	// A block may have points at the same timestamp, which are not overlaps.
+	first := compress(t, header, []point{{header, 1}, {header + 60, 2}, {header + 60, 3}})
+	second := compress(t, header+60, []point{{header + 60, 20}, {header + 120, 30}, {header + 120, 40}})
+
+	b, stats, err := gorilla.CompactWith(gorilla.CompactOptions{Overlap: gorilla.RejectOverlap}, first)
+	require.Nil(t, err)
+	_, points := decompress(t, b)
+	assert.Equal(t, []point{{header, 1}, {header + 60, 2}, {header + 60, 3}}, points)
+	assert.Equal(t, 0, stats.Dropped)
+
+	b, stats, err = gorilla.CompactWith(gorilla.CompactOptions{Overlap: gorilla.KeepFirst}, first, second)
+	require.Nil(t, err)
+	_, points = decompress(t, b)
+	assert.Equal(t, []point{{header, 1}, {header + 60, 2}, {header + 60, 3}, {header + 120, 30}, {header + 120, 40}}, points)
+	assert.Equal(t, 1, stats.Dropped)
+
+	b, stats, err = gorilla.CompactWith(gorilla.CompactOptions{Overlap: gorilla.KeepLast}, first, second)
+	require.Nil(t, err)
+	_, points = decompress(t, b)
+	assert.Equal(t, []point{{header, 1}, {header + 60, 20}, {header + 120, 30}, {header + 120, 40}}, points)
+	assert.Equal(t, 2, stats.Dropped)
+}
+
+func Test_Compact_FreshHeader(t *testing.T) {
+	const header = uint32(1600000000)
+	// The first point of the later block is too far from the earlier header to be
+	// encoded as the first delta, so the earlier block is empty on purpose.
+	empty := compress(t, header, nil)
+	later := series(header+20000, 10, 60, 0)
+
+	b, err := gorilla.Compact(empty, compress(t, header+20000, later))
+	require.Nil(t, err)
+	h, points := decompress(t, b)
+	assert.Equal(t, header+20000, h)
+	assert.Equal(t, later, points)
+}
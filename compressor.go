@@ -1,6 +1,7 @@
 package gorilla
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -10,13 +11,32 @@ const (
 	firstDeltaBits = 14
 )
 
+// ErrOutOfRange is returned for a timestamp which cannot be stored in a block.
+// Timestamps are seconds in [1, math.MaxUint32]; zero cannot be stored since
+// the compressor takes it as no point compressed. The first timestamp cannot be
+// stored either if it is before the header or its delta from the header
+// exceeds the bits of the Encoding of the block, nor can a later timestamp
+// whose delta of delta exceeds them.
+var ErrOutOfRange = errors.New("timestamp out of range")
+
+// UnixSeconds converts Unix seconds into a timestamp of a block, returning
+// ErrOutOfRange if it cannot be stored.
+func UnixSeconds(s int64) (uint32, error) {
+	if s <= 0 || math.MaxUint32 < s {
+		return 0, fmt.Errorf("%w: %d", ErrOutOfRange, s)
+	}
+	return uint32(s), nil
+}
+
 // Compressor compresses time-series data based on Facebook's paper.
 // Link to the paper: https://www.vldb.org/pvldb/vol8/p1816-teller.pdf
 type Compressor struct {
-	bw            *bitWriter
-	header        int32
-	t             int32
-	tDelta        int32
+	bw  *bitWriter
+	enc encoding
+	// header, t and tDelta are in the unit of enc.
+	header        int64
+	t             int64
+	tDelta        int64
 	leadingZeros  uint8
 	trailingZeros uint8
 	value         uint64
@@ -25,8 +45,19 @@ type Compressor struct {
 // NewCompressor initialize Compressor and returns a function to be invoked
 // at the end of compressing.
 func NewCompressor(w io.Writer, header uint32) (c *Compressor, finish func() error, err error) {
+	return NewCompressorWithEncoding(w, header, Encoding{})
+}
+
+// NewCompressorWithEncoding is like NewCompressor but compresses into the
+// wire variant of enc.
+func NewCompressorWithEncoding(w io.Writer, header uint32, enc Encoding) (c *Compressor, finish func() error, err error) {
+	e, err := enc.resolve()
+	if err != nil {
+		return nil, nil, err
+	}
 	c = &Compressor{
-		header:       int32(header),
+		header:       e.headerTicks(header),
+		enc:          e,
 		bw:           newBitWriter(w),
 		leadingZeros: math.MaxUint8,
 	}
@@ -38,21 +69,28 @@ func NewCompressor(w io.Writer, header uint32) (c *Compressor, finish func() err
 
 // Compress compresses time-series data and write.
 func (c *Compressor) Compress(t uint32, v float64) error {
+	if t == 0 {
+		return fmt.Errorf("%w: %d", ErrOutOfRange, t)
+	}
+	ticks, err := c.enc.ticks(t)
+	if err != nil {
+		return err
+	}
 	// First time to compress.
 	if c.t == 0 {
-		if int32(t)-c.header < 0 {
-			// Prevent overflowing of the delta of first timestamp but it updates
-			// `t` forcefully. So, it is not a good solution.
-			//
-			// TODO: Implement the better way to handle the case that `t` is smaller than `c.header`.
-			t = uint32(c.header)
+		delta := ticks - c.header
+		if delta < 0 {
+			return fmt.Errorf("%w: first timestamp %d is before the header", ErrOutOfRange, t)
+		}
+		// The delta of all '1' bits is the finish marker of an empty block.
+		if mask(c.enc.FirstDeltaBits) <= uint64(delta) {
+			return fmt.Errorf("%w: delta %d of the first timestamp %d from the header exceeds %d bits", ErrOutOfRange, delta, t, c.enc.FirstDeltaBits)
 		}
-		delta := int32(t) - c.header
-		c.t = int32(t)
+		c.t = ticks
 		c.tDelta = delta
 		c.value = math.Float64bits(v)
 
-		if err := c.bw.writeBits(uint64(delta), firstDeltaBits); err != nil {
+		if err := c.bw.writeBits(uint64(delta), c.enc.FirstDeltaBits); err != nil {
 			return fmt.Errorf("failed to write first timestamp: %w", err)
 		}
 		// The first value is stored with no compression.
@@ -61,10 +99,10 @@ func (c *Compressor) Compress(t uint32, v float64) error {
 		}
 		return nil
 	}
-	return c.compress(t, v)
+	return c.compress(ticks, v)
 }
 
-func (c *Compressor) compress(t uint32, v float64) error {
+func (c *Compressor) compress(t int64, v float64) error {
 	if err := c.compressTimestamp(t); err != nil {
 		return fmt.Errorf("failed to compress timestamp: %w", err)
 	}
@@ -74,12 +112,13 @@ func (c *Compressor) compress(t uint32, v float64) error {
 	return nil
 }
 
-func (c *Compressor) compressTimestamp(t uint32) error {
-	delta := int32(t) - c.t
-	dod := int64(delta) - int64(c.tDelta) // delta of delta
-	c.t = int32(t)
-	c.tDelta = delta
+func (c *Compressor) compressTimestamp(t int64) error {
+	delta := t - c.t
+	dod := delta - c.tDelta // delta of delta
 
+	// The delta of delta is stored in the smallest bucket it fits in.
+	// With DefaultBuckets:
+	//
 	// | DoD         | Header value | Value bits | Total bits |
 	// |-------------|------------- |------------|------------|
 	// | 0           | 0            | 0          | 1          |
@@ -87,60 +126,44 @@ func (c *Compressor) compressTimestamp(t uint32) error {
 	// | -255, 256   | 110          | 9          | 12         |
 	// | -2047, 2048 | 1110         | 12         | 16         |
 	// | > 2048      | 1111         | 32         | 36         |
-	switch {
-	case dod == 0:
-		if err := c.bw.writeBit(zero); err != nil {
-			return fmt.Errorf("failed to write timestamp zero: %w", err)
-		}
-
-	case -63 <= dod && dod <= 64:
-		// 0x02 == '10'
-		if err := c.bw.writeBits(0x02, 2); err != nil {
-			return fmt.Errorf("failed to write 2 bits header: %w", err)
-		}
-		if err := writeInt64Bits(c.bw, dod, 7); err != nil {
-			return fmt.Errorf("failed to write 7 bits dod: %w", err)
-		}
-
-	case -255 <= dod && dod <= 256:
-		// 0x06 == '110'
-		if err := c.bw.writeBits(0x06, 3); err != nil {
-			return fmt.Errorf("failed to write 3 bits header: %w", err)
+	buckets := c.enc.Buckets
+	i := 0
+	if dod != 0 {
+		for ; i < len(buckets); i++ {
+			if n := buckets[i]; n == 64 || -1<<(n-1) < dod && dod <= 1<<(n-1) {
+				break
+			}
 		}
-		if err := writeInt64Bits(c.bw, dod, 9); err != nil {
-			return fmt.Errorf("failed to write 9 bits dod: %w", err)
+		if i == len(buckets) {
+			return fmt.Errorf("%w: delta of delta %d exceeds %d bits", ErrOutOfRange, dod, buckets[len(buckets)-1])
 		}
-
-	case -2047 <= dod && dod <= 2048:
-		// 0x0E == '1110'
-		if err := c.bw.writeBits(0x0E, 4); err != nil {
-			return fmt.Errorf("failed to write 4 bits header: %w", err)
-		}
-		if err := writeInt64Bits(c.bw, dod, 12); err != nil {
-			return fmt.Errorf("failed to write 12 bits dod: %w", err)
+		if n := buckets[i]; i == len(buckets)-1 && uint64(dod)&mask(n) == mask(n) {
+			return fmt.Errorf("%w: delta of delta %d is the finish marker in %d bits", ErrOutOfRange, dod, n)
 		}
+	}
+	c.t = t
+	c.tDelta = delta
 
-	default:
-		// 0x0F == '1111'
-		if err := c.bw.writeBits(0x0F, 4); err != nil {
-			return fmt.Errorf("failed to write 4 bits header: %w", err)
-		}
-		if err := writeInt64Bits(c.bw, dod, 32); err != nil {
-			return fmt.Errorf("failed to write 32 bits dod: %w", err)
+	if dod == 0 {
+		if err := c.bw.writeBit(zero); err != nil {
+			return fmt.Errorf("failed to write timestamp zero: %w", err)
 		}
+		return nil
 	}
-
-	return nil
-}
-
-func writeInt64Bits(bw *bitWriter, i int64, nbits uint) error {
-	var u uint64
-	if i >= 0 || nbits >= 64 {
-		u = uint64(i)
-	} else {
-		u = uint64(1<<nbits + i)
+	n := buckets[i]
+	last := i == len(buckets)-1
+	// i+1 '1' bits, followed by a '0' except for the last bucket.
+	control, controlBits := uint64(1)<<(i+1)-1, i+1
+	if !last {
+		control, controlBits = control<<1, controlBits+1
+	}
+	if err := c.bw.writeBits(control, controlBits); err != nil {
+		return fmt.Errorf("failed to write %d bits header: %w", controlBits, err)
+	}
+	if err := c.bw.writeBits(uint64(dod)&mask(n), n); err != nil {
+		return fmt.Errorf("failed to write %d bits dod: %w", n, err)
 	}
-	return bw.writeBits(u, int(nbits))
+	return nil
 }
 
 func (c *Compressor) compressValue(v float64) error {
@@ -148,6 +171,10 @@ func (c *Compressor) compressValue(v float64) error {
 	xor := c.value ^ value
 	c.value = value
 
+	if c.enc.Values == RawValues {
+		return c.bw.writeBits(value, 64)
+	}
+
 	// Value is the same as previous.
 	if xor == 0 {
 		return c.bw.writeBit(zero)
@@ -218,31 +245,47 @@ func trailingZeros(v uint64) uint8 {
 
 // finish compresses the finish marker and flush bits with zero bits padding for byte-align.
 func (c *Compressor) finish() error {
+	return c.writeFinish(c.bw)
+}
+
+// Snapshot writes the bits buffered in c, followed by the finish marker, to w
+// without finishing c. The bytes c has written to its writer so far followed by
+// the bytes written to w form a complete block, while c can keep compressing.
+func (c *Compressor) Snapshot(w io.Writer) error {
+	bw := *c.bw
+	bw.w = w
+	return c.writeFinish(&bw)
+}
+
+func (c *Compressor) writeFinish(bw *bitWriter) error {
 	if c.t == 0 {
-		// Add finish marker with delta = 0x3FFF (firstDeltaBits = 14 bits), and first value = 0
-		err := c.bw.writeBits(1<<firstDeltaBits-1, firstDeltaBits)
+		// Add finish marker with all '1' bits of the first delta, and first value = 0
+		err := bw.writeBits(mask(c.enc.FirstDeltaBits), c.enc.FirstDeltaBits)
 		if err != nil {
 			return err
 		}
-		err = c.bw.writeBits(0, 64)
+		err = bw.writeBits(0, 64)
 		if err != nil {
 			return err
 		}
-		return c.bw.flush(zero)
+		return bw.flush(zero)
 	}
 
-	// Add finish marker with deltaOfDelta = 0xFFFFFFFF, and value xor = 0
-	err := c.bw.writeBits(0x0F, 4)
+	// Add finish marker with all '1' bits of the last delta of delta bucket,
+	// e.g. '1111' and 0xFFFFFFFF, and value xor = 0
+	n := len(c.enc.Buckets)
+	err := bw.writeBits(mask(n), n)
 	if err != nil {
 		return err
 	}
-	err = c.bw.writeBits(0xFFFFFFFF, 32)
+	last := c.enc.Buckets[n-1]
+	err = bw.writeBits(mask(last), last)
 	if err != nil {
 		return err
 	}
-	err = c.bw.writeBit(zero)
+	err = bw.writeBit(zero)
 	if err != nil {
 		return err
 	}
-	return c.bw.flush(zero)
+	return bw.flush(zero)
 }
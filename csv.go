@@ -0,0 +1,194 @@
+package gorilla
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Time formats of CSVOptions other than the layouts of the time package.
+const (
+	// TimeUnix formats timestamps as seconds since the Unix epoch.
+	TimeUnix = "unix"
+	// TimeUnixMilli formats timestamps as milliseconds since the Unix epoch.
+	TimeUnixMilli = "unix_ms"
+	// TimeUnixMicro formats timestamps as microseconds since the Unix epoch.
+	TimeUnixMicro = "unix_us"
+	// TimeUnixNano formats timestamps as nanoseconds since the Unix epoch.
+	TimeUnixNano = "unix_ns"
+)
+
+// CSVOptions configures ImportCSV and ExportCSV.
+type CSVOptions struct {
+	// Comma is the field delimiter. It defaults to ','.
+	Comma rune
+	// HasHeader is true if the first row is a header, which ImportCSV skips
+	// and ExportCSV writes.
+	HasHeader bool
+	// TimeColumn and ValueColumn are the zero-based indices of the timestamp
+	// and value columns ImportCSV reads. If both are zero, ValueColumn is 1.
+	TimeColumn  int
+	ValueColumn int
+	// TimeFormat is one of TimeUnix, TimeUnixMilli, TimeUnixMicro, TimeUnixNano
+	// or a layout of the time package. It defaults to TimeUnix. Sub-second
+	// precision is truncated on import.
+	TimeFormat string
+	// Location is the time zone of layouts without one. It defaults to UTC.
+	Location *time.Location
+	// BlockHeader is the header of the block ImportCSV compresses into.
+	// It defaults to the first timestamp.
+	BlockHeader uint32
+}
+
+func (opts CSVOptions) withDefaults() CSVOptions {
+	if opts.Comma == 0 {
+		opts.Comma = ','
+	}
+	if opts.TimeColumn == 0 && opts.ValueColumn == 0 {
+		opts.ValueColumn = 1
+	}
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = TimeUnix
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	return opts
+}
+
+// ImportCSV compresses rows of timestamps and values read from r into a block
+// written to w. Rows are read and compressed one by one, so neither r nor the
+// block needs to fit in memory; on error, w may hold part of the block.
+// Timestamps must not decrease.
+func ImportCSV(w io.Writer, r io.Reader, opts CSVOptions) error {
+	opts = opts.withDefaults()
+	cr := csv.NewReader(r)
+	cr.Comma = opts.Comma
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	bw := bufio.NewWriter(w)
+	var c *Compressor
+	var finish func() error
+	var last uint32
+	for row := 1; ; row++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read csv: %w", err)
+		}
+		if row == 1 && opts.HasHeader {
+			continue
+		}
+		t, v, err := opts.parseRecord(record)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", row, err)
+		}
+		if c == nil {
+			header := opts.BlockHeader
+			if header == 0 {
+				header = t
+			}
+			if c, finish, err = NewCompressor(bw, header); err != nil {
+				return err
+			}
+		} else if t < last {
+			return fmt.Errorf("row %d: timestamp %d is before %d", row, t, last)
+		}
+		if err := c.Compress(t, v); err != nil {
+			return fmt.Errorf("row %d: %w", row, err)
+		}
+		last = t
+	}
+	if c == nil {
+		return errors.New("no rows to import")
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (opts CSVOptions) parseRecord(record []string) (uint32, float64, error) {
+	for _, col := range []int{opts.TimeColumn, opts.ValueColumn} {
+		if len(record) <= col {
+			return 0, 0, fmt.Errorf("no column %d in %d columns", col, len(record))
+		}
+	}
+	t, err := opts.parseTime(record[opts.TimeColumn])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid timestamp %q: %w", record[opts.TimeColumn], err)
+	}
+	v, err := strconv.ParseFloat(record[opts.ValueColumn], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value %q: %w", record[opts.ValueColumn], err)
+	}
+	return t, v, nil
+}
+
+func (opts CSVOptions) parseTime(s string) (uint32, error) {
+	var sec int64
+	if unit, ok := unixUnits[opts.TimeFormat]; ok {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		sec = i / unit
+	} else {
+		tm, err := time.ParseInLocation(opts.TimeFormat, s, opts.Location)
+		if err != nil {
+			return 0, err
+		}
+		sec = tm.Unix()
+	}
+	return UnixSeconds(sec)
+}
+
+var unixUnits = map[string]int64{
+	TimeUnix:      1,
+	TimeUnixMilli: 1e3,
+	TimeUnixMicro: 1e6,
+	TimeUnixNano:  1e9,
+}
+
+// ExportCSV writes the points decompressed by d to w as rows of timestamps
+// and values, using opts.Comma, opts.HasHeader, opts.TimeFormat and opts.Location.
+// Points are written as they are decompressed, so they do not need to fit in memory.
+func ExportCSV(w io.Writer, d *Decompressor, opts CSVOptions) error {
+	opts = opts.withDefaults()
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.Comma
+	if opts.HasHeader {
+		if err := cw.Write([]string{"timestamp", "value"}); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+	record := make([]string, 2)
+	iter := d.Iterator()
+	for iter.Next() {
+		t, v := iter.At()
+		record[0] = opts.formatTime(t)
+		record[1] = strconv.FormatFloat(v, 'g', -1, 64)
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write row: %w", err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (opts CSVOptions) formatTime(t uint32) string {
+	if unit, ok := unixUnits[opts.TimeFormat]; ok {
+		return strconv.FormatInt(int64(t)*unit, 10)
+	}
+	return time.Unix(int64(t), 0).In(opts.Location).Format(opts.TimeFormat)
+}
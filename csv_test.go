@@ -0,0 +1,129 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ImportCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		opts gorilla.CSVOptions
+	}{
+		{
+			name: "default",
+			csv:  "1600000000,1.5\n1600000060,2\n1600000120,NaN\n",
+		},
+		{
+			name: "header, columns and delimiter",
+			csv:  "host;value;time\na;1.5;1600000000000\na;2;1600000060000\na;NaN;1600000120999\n",
+			opts: gorilla.CSVOptions{
+				Comma:       ';',
+				HasHeader:   true,
+				TimeColumn:  2,
+				ValueColumn: 1,
+				TimeFormat:  gorilla.TimeUnixMilli,
+			},
+		},
+		{
+			name: "layout",
+			csv:  "2020-09-13T12:26:40Z,1.5\n2020-09-13T12:27:40Z,2\n2020-09-13T21:28:40+09:00,NaN\n",
+			opts: gorilla.CSVOptions{TimeFormat: time.RFC3339},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			require.Nil(t, gorilla.ImportCSV(&b, strings.NewReader(tt.csv), tt.opts))
+			header, points := decompress(t, b.Bytes())
+			assert.Equal(t, uint32(1600000000), header)
+			require.Len(t, points, 3)
+			assert.Equal(t, []point{{1600000000, 1.5}, {1600000060, 2}}, points[:2])
+			assert.Equal(t, uint32(1600000120), points[2].t)
+			assert.True(t, math.IsNaN(points[2].v))
+		})
+	}
+}
+
+func Test_ImportCSV_error(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		opts gorilla.CSVOptions
+		want string
+	}{
+		{"empty", "", gorilla.CSVOptions{}, "no rows to import"},
+		{"missing column", "1600000000\n", gorilla.CSVOptions{}, "row 1: no column 1"},
+		{"invalid value", "1600000000,1\n1600000060,x\n", gorilla.CSVOptions{}, `row 2: invalid value "x"`},
+		{"invalid timestamp", "t,1\n", gorilla.CSVOptions{}, `row 1: invalid timestamp "t"`},
+		{"zero timestamp", "0,1\n", gorilla.CSVOptions{}, "row 1: invalid timestamp"},
+		{"decreasing", "1600000060,1\n1600000000,1\n", gorilla.CSVOptions{}, "row 2: timestamp 1600000000 is before 1600000060"},
+		{"far from header", "1600000000,1\n", gorilla.CSVOptions{BlockHeader: 1500000000}, "row 1: timestamp out of range: delta 100000000 of the first timestamp 1600000000 from the header exceeds 14 bits"},
+		{"before header", "1600000000,1\n", gorilla.CSVOptions{BlockHeader: 1600000060}, "row 1: timestamp out of range: first timestamp 1600000000 is before the header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := gorilla.ImportCSV(io.Discard, strings.NewReader(tt.csv), tt.opts)
+			require.NotNil(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func Test_ExportCSV(t *testing.T) {
+	b := compress(t, 1600000000, []point{{1600000000, 1.5}, {1600000060, math.Inf(-1)}, {1600000120, 1e21}})
+	tests := []struct {
+		name string
+		opts gorilla.CSVOptions
+		want string
+	}{
+		{
+			name: "default",
+			want: "1600000000,1.5\n1600000060,-Inf\n1600000120,1e+21\n",
+		},
+		{
+			name: "header and milliseconds",
+			opts: gorilla.CSVOptions{HasHeader: true, Comma: '\t', TimeFormat: gorilla.TimeUnixMilli},
+			want: "timestamp\tvalue\n1600000000000\t1.5\n1600000060000\t-Inf\n1600000120000\t1e+21\n",
+		},
+		{
+			name: "layout",
+			opts: gorilla.CSVOptions{TimeFormat: time.RFC3339, Location: time.FixedZone("JST", 9*60*60)},
+			want: "2020-09-13T21:26:40+09:00,1.5\n2020-09-13T21:27:40+09:00,-Inf\n2020-09-13T21:28:40+09:00,1e+21\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _, err := gorilla.NewDecompressor(bytes.NewReader(b))
+			require.Nil(t, err)
+			var buf bytes.Buffer
+			require.Nil(t, gorilla.ExportCSV(&buf, d, tt.opts))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func Test_CSV_roundTrip(t *testing.T) {
+	points := series(1600000000, 1000, 60, 0.5)
+	// Points at the same timestamp are valid in blocks.
+	points = append(points, point{points[len(points)-1].t, 1}, point{points[len(points)-1].t, 2})
+	d, _, err := gorilla.NewDecompressor(bytes.NewReader(compress(t, 1600000000, points)))
+	require.Nil(t, err)
+	var buf bytes.Buffer
+	opts := gorilla.CSVOptions{HasHeader: true, TimeFormat: time.RFC3339}
+	require.Nil(t, gorilla.ExportCSV(&buf, d, opts))
+
+	var b bytes.Buffer
+	require.Nil(t, gorilla.ImportCSV(&b, &buf, opts))
+	_, got := decompress(t, b.Bytes())
+	assert.Equal(t, points, got)
+}
@@ -7,22 +7,41 @@ import (
 	"math"
 )
 
+// errEndOfBlock is returned by the decompressor at the finish marker.
+// It wraps io.EOF so that the iterator ends without error.
+var errEndOfBlock = fmt.Errorf("end of block: %w", io.EOF)
+
 // Compressor decompresses time-series data based on Facebook's paper.
 // Link to the paper: https://www.vldb.org/pvldb/vol8/p1816-teller.pdf
 type Decompressor struct {
-	br            *bitReader
-	header        uint32
-	t             uint32
-	delta         uint32
+	br     *bitReader
+	enc    encoding
+	header uint32
+	// t and delta are in the unit of enc.
+	t             int64
+	delta         int64
 	leadingZeros  uint8
 	trailingZeros uint8
 	value         uint64
+	// trace records how the point being decompressed is encoded if not nil.
+	trace *pointTrace
 }
 
 // NewDecompressor initializes Decompressor and returns decompressed header.
 func NewDecompressor(r io.Reader) (d *Decompressor, header uint32, err error) {
+	return NewDecompressorWithEncoding(r, Encoding{})
+}
+
+// NewDecompressorWithEncoding is like NewDecompressor but decompresses a block
+// compressed with enc.
+func NewDecompressorWithEncoding(r io.Reader, enc Encoding) (d *Decompressor, header uint32, err error) {
+	e, err := enc.resolve()
+	if err != nil {
+		return nil, 0, err
+	}
 	d = &Decompressor{
-		br: newBitReader(r),
+		br:  newBitReader(r),
+		enc: e,
 	}
 	h, err := d.br.readBits(32)
 	if err != nil {
@@ -50,14 +69,23 @@ func (di *DecompressIterator) At() (t uint32, v float64) {
 	return di.t, di.v
 }
 
-// Err returns error during decompression.
+// Err returns error during decompression. It returns ErrTruncatedBlock if
+// the block ends before its finish marker.
 func (di *DecompressIterator) Err() error {
-	if errors.Is(di.err, io.EOF) {
+	if di.ended() {
 		return nil
 	}
+	if errors.Is(di.err, io.EOF) {
+		return ErrTruncatedBlock
+	}
 	return di.err
 }
 
+// ended reports whether the iterator reached the finish marker of the block.
+func (di *DecompressIterator) ended() bool {
+	return errors.Is(di.err, errEndOfBlock)
+}
+
 // Next proceeds decompressing time-series data unitil EOF.
 func (di *DecompressIterator) Next() bool {
 	if di.d.t == 0 {
@@ -69,12 +97,16 @@ func (di *DecompressIterator) Next() bool {
 }
 
 func (d *Decompressor) decompressFirst() (t uint32, v float64, err error) {
-	delta, err := d.br.readBits(firstDeltaBits)
+	n := d.enc.FirstDeltaBits
+	delta, err := d.br.readBits(n)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to decompress delta at first: %w", err)
 	}
-	if delta == 1<<firstDeltaBits-1 {
-		return 0, 0, io.EOF
+	if d.trace != nil {
+		d.trace.dodBits, d.trace.dodPayload, d.trace.dod = uint(n), delta, int64(delta)
+	}
+	if delta == mask(n) {
+		return 0, 0, errEndOfBlock
 	}
 
 	value, err := d.br.readBits(64)
@@ -82,11 +114,17 @@ func (d *Decompressor) decompressFirst() (t uint32, v float64, err error) {
 		return 0, 0, fmt.Errorf("failed to decompress value at first: %w", err)
 	}
 
-	d.delta = uint32(delta)
-	d.t = d.header + d.delta
+	d.delta = int64(delta)
+	d.t = d.enc.headerTicks(d.header) + d.delta
 	d.value = value
+	if d.trace != nil {
+		d.trace.valueCase, d.trace.valuePayload = valueFirst, value
+	}
 
-	return d.t, math.Float64frombits(d.value), nil
+	if t, err = d.enc.seconds(d.t); err != nil {
+		return 0, 0, fmt.Errorf("failed to decompress first timestamp: %w", err)
+	}
+	return t, math.Float64frombits(d.value), nil
 }
 
 func (d *Decompressor) decompress() (t uint32, v float64, err error) {
@@ -104,68 +142,67 @@ func (d *Decompressor) decompress() (t uint32, v float64, err error) {
 }
 
 func (d *Decompressor) decompressTimestamp() (uint32, error) {
-	n, err := d.dodTimestampBitN()
+	i, err := d.dodBucket()
 	if err != nil {
 		return 0, err
 	}
 
-	if n == 0 {
+	if i < 0 {
 		d.t += d.delta
-		return d.t, nil
+		return d.enc.seconds(d.t)
 	}
 
-	bits, err := d.br.readBits(int(n))
+	n := d.enc.Buckets[i]
+	bits, err := d.br.readBits(n)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read timestamp: %w", err)
 	}
+	if d.trace != nil {
+		d.trace.dodBits, d.trace.dodPayload = uint(n), bits
+	}
 
-	if n == 32 && bits == 0xFFFFFFFF {
-		return 0, io.EOF
+	if i == len(d.enc.Buckets)-1 && bits == mask(n) {
+		return 0, errEndOfBlock
 	}
 
 	var dod int64 = int64(bits)
-	if n != 32 && 1<<(n-1) < int64(bits) {
-		dod = int64(bits - 1<<n)
+	if n != 64 && 1<<(n-1) < bits {
+		dod = int64(bits) - 1<<n
+	}
+	if d.trace != nil {
+		d.trace.dod = dod
 	}
 
-	d.delta += uint32(dod)
+	d.delta += dod
 	d.t += d.delta
-	return d.t, nil
+	return d.enc.seconds(d.t)
 }
 
-// returning the amount of delta-of-delta timestamp bits.
-func (d *Decompressor) dodTimestampBitN() (n uint, err error) {
-	var dod byte
-	for i := 0; i < 4; i++ {
-		dod <<= 1
+// dodBucket returns the index of the bucket of the delta of delta read from
+// its control bits, or -1 for the delta of delta of zero.
+func (d *Decompressor) dodBucket() (int, error) {
+	n := len(d.enc.Buckets)
+	for i := 0; i < n; i++ {
 		b, err := d.br.readBit()
 		if err != nil {
 			return 0, err
 		}
-		if b {
-			dod |= 1
-		} else {
-			break
+		if !b {
+			return i - 1, nil
 		}
 	}
-
-	switch dod {
-	case 0x00: // 0
-		return 0, nil
-	case 0x02: // 10
-		return 7, nil
-	case 0x06: // 110
-		return 9, nil
-	case 0x0E: // 1110
-		return 12, nil
-	case 0x0F: // 1111
-		return 32, nil
-	default:
-		return 0, errors.New("invalid bit header for bit length to read")
-	}
+	return n - 1, nil
 }
 
 func (d *Decompressor) decompressValue() (float64, error) {
+	if d.enc.Values == RawValues {
+		value, err := d.br.readBits(64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read value: %w", err)
+		}
+		d.value = value
+		return math.Float64frombits(d.value), nil
+	}
 	var read byte
 	for i := 0; i < 2; i++ {
 		bit, err := d.br.readBit()
@@ -192,6 +229,9 @@ func (d *Decompressor) decompressValue() (float64, error) {
 			if significantBits == 0 {
 				significantBits = 64
 			}
+			if 64 < leadingZeros+significantBits {
+				return 0, fmt.Errorf("invalid meaningful bits: %d leading zeros and %d significant bits", leadingZeros, significantBits)
+			}
 			d.leadingZeros = uint8(leadingZeros)
 			d.trailingZeros = 64 - uint8(significantBits) - d.leadingZeros
 		}
@@ -200,8 +240,17 @@ func (d *Decompressor) decompressValue() (float64, error) {
 		if err != nil {
 			return 0, fmt.Errorf("failed to read value: %w", err)
 		}
+		if d.trace != nil {
+			d.trace.valueCase, d.trace.valuePayload = valueReuse, valueBits
+			if read == 0x3 {
+				d.trace.valueCase = valueNew
+			}
+			d.trace.leadingZeros, d.trace.trailingZeros = d.leadingZeros, d.trailingZeros
+		}
 		valueBits <<= uint64(d.trailingZeros)
 		d.value ^= valueBits
+	} else if d.trace != nil {
+		d.trace.valueCase = valueSame
 	}
 	return math.Float64frombits(d.value), nil
 }
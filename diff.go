@@ -0,0 +1,89 @@
+package gorilla
+
+import (
+	"fmt"
+	"math"
+)
+
+// DiffKind is a kind of Difference.
+type DiffKind int
+
+const (
+	// Missing is a point in the first series whose timestamp is not in the second.
+	Missing DiffKind = iota
+	// Extra is a point in the second series whose timestamp is not in the first.
+	Extra
+	// Changed is a timestamp in both series with different values.
+	Changed
+)
+
+// Difference is a difference between two series found by Diff.
+type Difference struct {
+	Kind DiffKind
+	T    uint32
+	// A and B are the values of the first and second series. A of Extra and
+	// B of Missing are zero.
+	A, B float64
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case Missing:
+		return fmt.Sprintf("- %d %v", d.T, d.A)
+	case Extra:
+		return fmt.Sprintf("+ %d %v", d.T, d.B)
+	}
+	return fmt.Sprintf("~ %d %v -> %v", d.T, d.A, d.B)
+}
+
+// DiffOptions configures Diff.
+type DiffOptions struct {
+	// Tolerance is the maximum absolute difference of values to be equal.
+	// If it is zero, values are compared bitwise.
+	Tolerance float64
+}
+
+// Diff decompresses a and b together and returns their differences ordered by
+// timestamp. NaNs are equal to each other, and infinities to those of the same sign.
+func Diff(a, b *Decompressor, opts DiffOptions) ([]Difference, error) {
+	ai, bi := a.Iterator(), b.Iterator()
+	aok, bok := ai.Next(), bi.Next()
+	var diffs []Difference
+	for aok || bok {
+		at, av := ai.At()
+		bt, bv := bi.At()
+		switch {
+		case aok && (!bok || at < bt):
+			diffs = append(diffs, Difference{Kind: Missing, T: at, A: av})
+			aok = ai.Next()
+		case bok && (!aok || bt < at):
+			diffs = append(diffs, Difference{Kind: Extra, T: bt, B: bv})
+			bok = bi.Next()
+		default:
+			if !opts.equal(av, bv) {
+				diffs = append(diffs, Difference{Kind: Changed, T: at, A: av, B: bv})
+			}
+			aok, bok = ai.Next(), bi.Next()
+		}
+	}
+	if err := ai.Err(); err != nil {
+		return diffs, fmt.Errorf("failed to decompress the first series: %w", err)
+	}
+	if err := bi.Err(); err != nil {
+		return diffs, fmt.Errorf("failed to decompress the second series: %w", err)
+	}
+	return diffs, nil
+}
+
+func (opts DiffOptions) equal(a, b float64) bool {
+	if math.Float64bits(a) == math.Float64bits(b) {
+		return true
+	}
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	if opts.Tolerance == 0 || math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return false
+	}
+	return math.Abs(a-b) <= opts.Tolerance
+}
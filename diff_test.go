@@ -0,0 +1,45 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diff(t *testing.T, a, b []point, opts gorilla.DiffOptions) []gorilla.Difference {
+	t.Helper()
+	da, _, err := gorilla.NewDecompressor(bytes.NewReader(compress(t, 1600000000, a)))
+	require.Nil(t, err)
+	db, _, err := gorilla.NewDecompressor(bytes.NewReader(compress(t, 1600000000, b)))
+	require.Nil(t, err)
+	diffs, err := gorilla.Diff(da, db, opts)
+	require.Nil(t, err)
+	return diffs
+}
+
+func Test_Diff(t *testing.T) {
+	a := []point{{1600000000, 1}, {1600000060, 2}, {1600000120, math.NaN()}, {1600000180, math.Inf(1)}, {1600000240, 0}}
+	assert.Empty(t, diff(t, a, a, gorilla.DiffOptions{}))
+
+	b := []point{{1600000060, 2.0000001}, {1600000090, 5}, {1600000120, math.Float64frombits(0x7FF8000000000001)}, {1600000180, math.Inf(-1)}, {1600000240, math.Copysign(0, -1)}, {1600000300, 6}}
+	diffs := diff(t, a, b, gorilla.DiffOptions{})
+	require.Len(t, diffs, 6)
+	assert.Equal(t, gorilla.Difference{Kind: gorilla.Missing, T: 1600000000, A: 1}, diffs[0])
+	assert.Equal(t, gorilla.Difference{Kind: gorilla.Changed, T: 1600000060, A: 2, B: 2.0000001}, diffs[1])
+	assert.Equal(t, gorilla.Difference{Kind: gorilla.Extra, T: 1600000090, B: 5}, diffs[2])
+	assert.Equal(t, gorilla.Difference{Kind: gorilla.Changed, T: 1600000180, A: math.Inf(1), B: math.Inf(-1)}, diffs[3])
+	assert.Equal(t, uint32(1600000240), diffs[4].T, "zeros of different signs differ bitwise")
+	assert.Equal(t, gorilla.Difference{Kind: gorilla.Extra, T: 1600000300, B: 6}, diffs[5])
+
+	assert.Equal(t, "- 1600000000 1", diffs[0].String())
+	assert.Equal(t, "+ 1600000090 5", diffs[2].String())
+	assert.Equal(t, "~ 1600000180 +Inf -> -Inf", diffs[3].String())
+
+	diffs = diff(t, a, b, gorilla.DiffOptions{Tolerance: 1e-6})
+	require.Len(t, diffs, 4)
+	assert.Equal(t, []uint32{1600000000, 1600000090, 1600000180, 1600000300}, []uint32{diffs[0].T, diffs[1].T, diffs[2].T, diffs[3].T})
+}
@@ -0,0 +1,2 @@
+// Package downsample provides rollups of gorilla blocks aggregated over fixed steps.
+package downsample
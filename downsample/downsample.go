@@ -0,0 +1,180 @@
+package downsample
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/keisku/gorilla"
+)
+
+// Common steps in seconds.
+const (
+	FiveMinutes = 5 * 60
+	OneHour     = 60 * 60
+)
+
+// Aggregate is a function rolling up the points in a step.
+type Aggregate int
+
+const (
+	Min Aggregate = iota
+	Max
+	Sum
+	Count
+	Last
+)
+
+// Aggregates lists every Aggregate.
+var Aggregates = []Aggregate{Min, Max, Sum, Count, Last}
+
+func (a Aggregate) String() string {
+	switch a {
+	case Min:
+		return "min"
+	case Max:
+		return "max"
+	case Sum:
+		return "sum"
+	case Count:
+		return "count"
+	case Last:
+		return "last"
+	default:
+		return fmt.Sprintf("Aggregate(%d)", int(a))
+	}
+}
+
+// Iterator iterates over time-series data, e.g. a *gorilla.DecompressIterator.
+type Iterator interface {
+	Next() bool
+	At() (t uint32, v float64)
+	Err() error
+}
+
+// Downsample rolls up the points of iters, read one after another, into
+// buckets of step seconds and returns a gorilla block per aggregate.
+// Each rollup point is stamped with the start of its bucket, a multiple of step.
+// NaN values are skipped.
+func Downsample(step uint32, iters ...Iterator) (map[Aggregate][]byte, error) {
+	if step == 0 {
+		return nil, errors.New("step must be positive")
+	}
+	r := &rollup{step: step}
+	for i, iter := range iters {
+		for iter.Next() {
+			t, v := iter.At()
+			if math.IsNaN(v) {
+				continue
+			}
+			if err := r.add(t, v); err != nil {
+				return nil, err
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to iterate %d: %w", i, err)
+		}
+	}
+	return r.finish()
+}
+
+type rollup struct {
+	step  uint32
+	out   map[Aggregate]*output
+	start uint32 // start of the current bucket
+	count int
+	min   float64
+	max   float64
+	sum   float64
+	last  float64
+}
+
+type output struct {
+	buf    *bytes.Buffer
+	c      *gorilla.Compressor
+	finish func() error
+}
+
+func (r *rollup) add(t uint32, v float64) error {
+	start := t - t%r.step
+	if r.out == nil {
+		if err := r.open(start); err != nil {
+			return err
+		}
+		r.start = start
+	}
+	if start < r.start {
+		return fmt.Errorf("point at %d is older than the current bucket starting at %d", t, r.start)
+	}
+	if r.start < start {
+		if err := r.flush(); err != nil {
+			return err
+		}
+		r.start = start
+	}
+	if r.count == 0 || v < r.min {
+		r.min = v
+	}
+	if r.count == 0 || r.max < v {
+		r.max = v
+	}
+	r.sum += v
+	r.last = v
+	r.count++
+	return nil
+}
+
+func (r *rollup) open(header uint32) error {
+	r.out = make(map[Aggregate]*output, len(Aggregates))
+	for _, a := range Aggregates {
+		buf := new(bytes.Buffer)
+		c, finish, err := gorilla.NewCompressor(buf, header)
+		if err != nil {
+			return fmt.Errorf("failed to open %s block: %w", a, err)
+		}
+		r.out[a] = &output{buf, c, finish}
+	}
+	return nil
+}
+
+// flush writes the current bucket to the blocks.
+func (r *rollup) flush() error {
+	if r.count == 0 {
+		return nil
+	}
+	values := map[Aggregate]float64{
+		Min:   r.min,
+		Max:   r.max,
+		Sum:   r.sum,
+		Count: float64(r.count),
+		Last:  r.last,
+	}
+	for a, o := range r.out {
+		if err := o.c.Compress(r.start, values[a]); err != nil {
+			return fmt.Errorf("failed to compress %s: %w", a, err)
+		}
+	}
+	r.count = 0
+	r.sum = 0
+	return nil
+}
+
+func (r *rollup) finish() (map[Aggregate][]byte, error) {
+	if r.out == nil {
+		if err := r.open(0); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.flush(); err != nil {
+		return nil, err
+	}
+	blocks := make(map[Aggregate][]byte, len(r.out))
+	for a, o := range r.out {
+		if err := o.finish(); err != nil {
+			return nil, fmt.Errorf("failed to finish %s block: %w", a, err)
+		}
+		blocks[a] = o.buf.Bytes()
+	}
+	return blocks, nil
+}
@@ -0,0 +1,90 @@
+package downsample_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/downsample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type point struct {
+	t uint32
+	v float64
+}
+
+func compress(t *testing.T, header uint32, points []point) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	c, finish, err := gorilla.NewCompressor(buf, header)
+	require.Nil(t, err)
+	for _, p := range points {
+		require.Nil(t, c.Compress(p.t, p.v))
+	}
+	require.Nil(t, finish())
+	return buf.Bytes()
+}
+
+func iterator(t *testing.T, b []byte) *gorilla.DecompressIterator {
+	t.Helper()
+	d, _, err := gorilla.NewDecompressor(bytes.NewReader(b))
+	require.Nil(t, err)
+	return d.Iterator()
+}
+
+func decompress(t *testing.T, b []byte) (uint32, []point) {
+	t.Helper()
+	d, header, err := gorilla.NewDecompressor(bytes.NewReader(b))
+	require.Nil(t, err)
+	var points []point
+	iter := d.Iterator()
+	for iter.Next() {
+		ts, v := iter.At()
+		points = append(points, point{ts, v})
+	}
+	require.Nil(t, iter.Err())
+	return header, points
+}
+
+func Test_Downsample(t *testing.T) {
+	const start = uint32(1600000200) // a multiple of 300
+	first := []point{{start + 10, 3}, {start + 70, 1}, {start + 130, math.NaN()}, {start + 190, 5}}
+	second := []point{{start + 300, 2}, {start + 360, -4}, {start + 900, 7}}
+
+	blocks, err := downsample.Downsample(downsample.FiveMinutes,
+		iterator(t, compress(t, start, first)), iterator(t, compress(t, start+300, second)))
+	require.Nil(t, err)
+
+	want := map[downsample.Aggregate][]point{
+		downsample.Min:   {{start, 1}, {start + 300, -4}, {start + 900, 7}},
+		downsample.Max:   {{start, 5}, {start + 300, 2}, {start + 900, 7}},
+		downsample.Sum:   {{start, 9}, {start + 300, -2}, {start + 900, 7}},
+		downsample.Count: {{start, 3}, {start + 300, 2}, {start + 900, 1}},
+		downsample.Last:  {{start, 5}, {start + 300, -4}, {start + 900, 7}},
+	}
+	require.Len(t, blocks, len(want))
+	for a, points := range want {
+		header, got := decompress(t, blocks[a])
+		assert.Equal(t, start, header, a.String())
+		assert.Equal(t, points, got, a.String())
+	}
+}
+
+func Test_Downsample_Empty(t *testing.T) {
+	blocks, err := downsample.Downsample(downsample.OneHour, iterator(t, compress(t, 1600000000, nil)))
+	require.Nil(t, err)
+	for _, a := range downsample.Aggregates {
+		_, points := decompress(t, blocks[a])
+		assert.Empty(t, points)
+	}
+}
+
+func Test_Downsample_OutOfOrder(t *testing.T) {
+	const start = uint32(1600000200)
+	b := compress(t, start, []point{{start + 600, 1}, {start, 2}})
+	_, err := downsample.Downsample(downsample.FiveMinutes, iterator(t, b))
+	assert.NotNil(t, err)
+}
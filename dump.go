@@ -0,0 +1,108 @@
+package gorilla
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Dump decompresses the block read from r and writes to w how each point is
+// encoded: its bit offset, the control bits and payload of the delta of delta
+// of its timestamp, the control bits, meaningful bits window and payload of its
+// value, and the decoded point. It ends with the Analysis of the block.
+//
+// Points are written until the finish marker or the damage of the block,
+// in which case Dump returns the error.
+func Dump(w io.Writer, r io.Reader) error {
+	cr := &countingReader{r: r}
+	d, header, err := NewDecompressor(cr)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	a := Analysis{Header: header}
+	offset, err := d.traceAll(func(p *pointTrace) {
+		if a.Points == 0 {
+			fmt.Fprintf(bw, "%-6s %-8s %-10s %-24s %-6s %-32s %-11s %-6s %-8s %s\n",
+				"POINT", "OFFSET", "TIMESTAMP", "VALUE", "T-CTRL", "T-BITS", "DOD", "V-CTRL", "WINDOW", "V-BITS")
+		}
+		dumpPoint(bw, a.Points, p)
+		a.add(p)
+	})
+	switch {
+	case errors.Is(err, errEndOfBlock):
+		fmt.Fprintf(bw, "finish marker at bit %d\n", offset)
+		err = nil
+	case errors.Is(err, io.EOF):
+		fmt.Fprintf(bw, "truncated at bit %d\n", offset)
+		err = ErrTruncatedBlock
+	default:
+		fmt.Fprintf(bw, "damaged at bit %d: %v\n", offset, err)
+	}
+
+	a.Bytes = cr.n
+	a.WriteTo(bw)
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+func dumpPoint(w io.Writer, i int, p *pointTrace) {
+	var dodBits, xorBits, window string
+	if p.dodBits != 0 {
+		dodBits = fmt.Sprintf("%0*b", p.dodBits, p.dodPayload)
+	}
+	switch p.valueCase {
+	case valueFirst:
+		xorBits = fmt.Sprintf("%064b", p.valuePayload)
+	case valueReuse, valueNew:
+		window = fmt.Sprintf("%d+%d+%d", p.leadingZeros, p.significantBits(), p.trailingZeros)
+		xorBits = fmt.Sprintf("%0*b", p.significantBits(), p.valuePayload)
+	}
+	dodControl, valueControl := p.dodControl(), p.valueControl()
+	if p.valueCase == valueFirst {
+		dodControl, valueControl = "-", "-"
+	}
+	fmt.Fprintf(w, "%-6d %-8d %-10d %-24v %-6s %-32s %-11d %-6s %-8s %s\n",
+		i, p.offset, p.t, p.v, dodControl, dodBits, p.dod, valueControl, window, xorBits)
+}
+
+var dodBuckets = []struct {
+	control string
+	desc    string
+}{
+	{"0", "0"},
+	{"10", "[-63, 64]"},
+	{"110", "[-255, 256]"},
+	{"1110", "[-2047, 2048]"},
+	{"1111", "32 bits"},
+}
+
+// dodBucket returns the index in dodBuckets of the bucket of n bits.
+func dodBucket(n uint) int {
+	switch n {
+	case 0:
+		return 0
+	case 7:
+		return 1
+	case 9:
+		return 2
+	case 12:
+		return 3
+	}
+	return 4
+}
+
+func share(n, total int) string {
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(%.1f%%)", float64(n)/float64(total)*100)
+}
+
+func formatUnix(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,46 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Dump(t *testing.T) {
+	b := compress(t, 1600000000, []point{
+		{1600000010, 1.5},
+		{1600000070, 1.5},
+		{1600000131, 2},
+		{1600000191, 2.5},
+		{1600005191, 2.5},
+	})
+	var buf bytes.Buffer
+	require.Nil(t, gorilla.Dump(&buf, bytes.NewReader(b)))
+	out := buf.String()
+	lines := strings.Split(out, "\n")
+	assert.Equal(t, "POINT", strings.Fields(lines[0])[0])
+	assert.Equal(t, []string{"0", "32", "1600000010", "1.5", "-", "00000000001010", "10", "-", "0011111111111000000000000000000000000000000000000000000000000000"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"1", "110", "1600000070", "1.5", "10", "0110010", "50", "0"}, strings.Fields(lines[2]))
+	assert.Equal(t, []string{"2", "120", "1600000131", "2", "10", "0000001", "1", "11", "1+12+51", "111111111111"}, strings.Fields(lines[3]))
+	assert.Equal(t, []string{"3", "154", "1600000191", "2.5", "10", "1111111", "-1", "11", "13+1+50", "1"}, strings.Fields(lines[4]))
+	assert.Equal(t, []string{"4", "177", "1600005191", "2.5", "1111", "00000000000000000001001101001100", "4940", "0"}, strings.Fields(lines[5]))
+	assert.Contains(t, out, "finish marker at bit 214\nheader           1600000000 (2020-09-13T12:26:40Z)\npoints           5\n")
+	assert.Contains(t, out, "dod '10'   [-63, 64]                3 (75.0%)")
+	assert.Contains(t, out, "dod '1111' 32 bits                  1 (25.0%)")
+	assert.Contains(t, out, "xor '11'   new window               2 (50.0%)")
+}
+
+func Test_Dump_truncated(t *testing.T) {
+	b := compress(t, 1600000000, series(1600000000, 3, 60, 1))
+	var buf bytes.Buffer
+	err := gorilla.Dump(&buf, bytes.NewReader(b[:14]))
+	assert.True(t, errors.Is(err, gorilla.ErrTruncatedBlock), err)
+	assert.Contains(t, buf.String(), "truncated at bit")
+
+	assert.NotNil(t, gorilla.Dump(&buf, bytes.NewReader(b[:2])))
+}
@@ -0,0 +1,153 @@
+package gorilla
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBuckets are the bit widths of the delta of delta buckets of the paper.
+var DefaultBuckets = []int{7, 9, 12, 32}
+
+// ValueCodec is the encoding of the values of a block following the first one,
+// which is always stored in 64 bits.
+type ValueCodec int
+
+const (
+	// XORValues stores the XOR of each value with the previous one in a window
+	// of meaningful bits as in the paper.
+	XORValues ValueCodec = iota
+	// RawValues stores each value in 64 bits.
+	RawValues
+)
+
+func (vc ValueCodec) String() string {
+	switch vc {
+	case XORValues:
+		return "xor"
+	case RawValues:
+		return "raw"
+	}
+	return "ValueCodec(" + strconv.Itoa(int(vc)) + ")"
+}
+
+// Encoding configures the wire variant of a block, e.g. to read and write the
+// blocks of other Gorilla implementations. A block must be decompressed with
+// the Encoding it was compressed with. The zero Encoding is the variant of the
+// paper used by NewCompressor and NewDecompressor.
+//
+// Timestamps are passed to Compressor and returned by Decompressor in seconds
+// whatever the Unit, and the header of a block is always 32 bits of seconds.
+type Encoding struct {
+	// Unit is the unit of the timestamps stored in a block, which must divide
+	// a second or be a multiple of it. Defaults to time.Second.
+	Unit time.Duration
+	// FirstDeltaBits is the bit width of the delta between the header and the
+	// first timestamp in Unit. Defaults to 14.
+	FirstDeltaBits int
+	// Buckets are the bit widths of the non-zero delta of delta in ascending
+	// order. The delta of delta in the i-th bucket is preceded by i+1 '1'
+	// control bits and a '0', except in the last bucket which needs no '0',
+	// like '10', '110', '1110' and '1111' of DefaultBuckets. A bucket of n bits
+	// holds [-2^(n-1)+1, 2^(n-1)], or any delta of delta if n is 64.
+	// Defaults to DefaultBuckets.
+	Buckets []int
+	// Values is the encoding of values. Defaults to XORValues.
+	Values ValueCodec
+}
+
+func (e Encoding) String() string {
+	e = e.withDefaults()
+	widths := make([]string, len(e.Buckets))
+	for i, n := range e.Buckets {
+		widths[i] = strconv.Itoa(n)
+	}
+	return fmt.Sprintf("unit=%s first-delta=%d buckets=%s values=%s", e.Unit, e.FirstDeltaBits, strings.Join(widths, ","), e.Values)
+}
+
+func (e Encoding) withDefaults() Encoding {
+	if e.Unit == 0 {
+		e.Unit = time.Second
+	}
+	if e.FirstDeltaBits == 0 {
+		e.FirstDeltaBits = firstDeltaBits
+	}
+	if len(e.Buckets) == 0 {
+		e.Buckets = DefaultBuckets
+	}
+	return e
+}
+
+// encoding is a validated Encoding with its defaults.
+type encoding struct {
+	Encoding
+	// perSecond is the number of units in a second if Unit divides a second.
+	perSecond int64
+	// perUnit is the number of seconds in a unit if Unit is a multiple of a second.
+	perUnit int64
+}
+
+func (e Encoding) resolve() (encoding, error) {
+	e = e.withDefaults()
+	if e.Unit < 0 || time.Second%e.Unit != 0 && e.Unit%time.Second != 0 {
+		return encoding{}, fmt.Errorf("unit %s neither divides a second nor is a multiple of it", e.Unit)
+	}
+	if e.FirstDeltaBits < 1 || 63 < e.FirstDeltaBits {
+		return encoding{}, fmt.Errorf("first delta bits must be in [1, 63]: %d", e.FirstDeltaBits)
+	}
+	for i, n := range e.Buckets {
+		if n < 1 || 64 < n || 0 < i && n <= e.Buckets[i-1] {
+			return encoding{}, fmt.Errorf("buckets must be ascending bit widths in [1, 64]: %v", e.Buckets)
+		}
+	}
+	if e.Values != XORValues && e.Values != RawValues {
+		return encoding{}, fmt.Errorf("unknown value codec: %s", e.Values)
+	}
+	enc := encoding{Encoding: e}
+	if e.Unit <= time.Second {
+		enc.perSecond = int64(time.Second / e.Unit)
+	} else {
+		enc.perUnit = int64(e.Unit / time.Second)
+	}
+	return enc, nil
+}
+
+// ticks converts the timestamp t in seconds into Unit.
+func (e *encoding) ticks(t uint32) (int64, error) {
+	if e.perSecond != 0 {
+		return int64(t) * e.perSecond, nil
+	}
+	if int64(t)%e.perUnit != 0 {
+		return 0, fmt.Errorf("timestamp %d is not in whole %s", t, e.Unit)
+	}
+	return int64(t) / e.perUnit, nil
+}
+
+// headerTicks converts the header in seconds into Unit, rounding down.
+func (e *encoding) headerTicks(header uint32) int64 {
+	if e.perSecond != 0 {
+		return int64(header) * e.perSecond
+	}
+	return int64(header) / e.perUnit
+}
+
+// seconds converts the timestamp t in Unit into seconds, which wrap around
+// like the uint32 timestamps of Compressor.
+func (e *encoding) seconds(t int64) (uint32, error) {
+	if e.perSecond == 0 {
+		return uint32(t * e.perUnit), nil
+	}
+	if t%e.perSecond != 0 {
+		return 0, fmt.Errorf("timestamp %d in %s is not in whole seconds", t, e.Unit)
+	}
+	return uint32(t / e.perSecond), nil
+}
+
+// mask returns the n right-most bits set, the finish marker of a field of n bits.
+func mask(n int) uint64 {
+	if n == 64 {
+		return 1<<64 - 1
+	}
+	return 1<<n - 1
+}
@@ -0,0 +1,3 @@
+// Package generator generates synthetic series shaped like real telemetry
+// for benchmarking and testing the compression.
+package generator
@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/keisku/gorilla"
+)
+
+// Options configures the timestamps of a generated series.
+type Options struct {
+	// Start is the first timestamp. It defaults to 1600000000.
+	Start uint32
+	// Interval is the seconds between points. It defaults to 10.
+	Interval uint32
+	// Points is the number of points, including missing ones.
+	Points int
+	// Jitter is the maximum seconds a timestamp deviates from its interval,
+	// which is capped so that timestamps keep increasing.
+	Jitter uint32
+	// GapRate is the probability of a point to be missing.
+	GapRate float64
+	// Seed seeds the randomness, making series reproducible.
+	Seed int64
+}
+
+// Values generates the values of a series.
+type Values interface {
+	// Next returns the next value.
+	Next(r *rand.Rand) float64
+}
+
+// ValuesFunc is an adapter to use an ordinary function as Values.
+type ValuesFunc func(r *rand.Rand) float64
+
+// Next calls f(r).
+func (f ValuesFunc) Next(r *rand.Rand) float64 {
+	return f(r)
+}
+
+// Generate returns the points of a series with timestamps of opts and values of values.
+func Generate(opts Options, values Values) []gorilla.Point {
+	if opts.Start == 0 {
+		opts.Start = 1600000000
+	}
+	if opts.Interval == 0 {
+		opts.Interval = 10
+	}
+	jitter := opts.Jitter
+	if limit := (opts.Interval - 1) / 2; limit < jitter {
+		jitter = limit
+	}
+	r := rand.New(rand.NewSource(opts.Seed))
+	points := make([]gorilla.Point, 0, opts.Points)
+	for i := 0; i < opts.Points; i++ {
+		v := values.Next(r)
+		if r.Float64() < opts.GapRate {
+			continue
+		}
+		t := opts.Start + uint32(i)*opts.Interval
+		if 0 < jitter && 0 < i {
+			t = t - jitter + uint32(r.Intn(int(2*jitter+1)))
+		}
+		points = append(points, gorilla.Point{T: t, V: v})
+	}
+	return points
+}
+
+// Constant returns values of a constant gauge.
+func Constant(v float64) Values {
+	return ValuesFunc(func(*rand.Rand) float64 { return v })
+}
+
+// Counter returns values of a monotonic counter increasing by rate per point
+// on average, which resets to zero with the probability of resetRate.
+func Counter(rate float64, resetRate float64) Values {
+	var v float64
+	return ValuesFunc(func(r *rand.Rand) float64 {
+		if r.Float64() < resetRate {
+			v = 0
+		}
+		v += math.Round(rate * 2 * r.Float64())
+		return v
+	})
+}
+
+// RandomWalk returns values starting at start and moving by normally distributed
+// steps of stddev, rounded to the multiples of precision if it is positive.
+func RandomWalk(start, stddev, precision float64) Values {
+	v := start
+	return ValuesFunc(func(r *rand.Rand) float64 {
+		v += r.NormFloat64() * stddev
+		if 0 < precision {
+			return math.Round(v/precision) * precision
+		}
+		return v
+	})
+}
+
+// Noise returns normally distributed values of mean and stddev at full precision.
+func Noise(mean, stddev float64) Values {
+	return ValuesFunc(func(r *rand.Rand) float64 {
+		return mean + r.NormFloat64()*stddev
+	})
+}
+
+// Workload is a named series shape.
+type Workload struct {
+	Name    string
+	Options Options
+	// Values returns new Values, since they may have state.
+	Values func() Values
+}
+
+// Generate returns the points of w.
+func (w Workload) Generate() []gorilla.Point {
+	return Generate(w.Options, w.Values())
+}
+
+// Workloads returns workloads shaped like real telemetry, of points at 10
+// second intervals seeded with seed.
+func Workloads(points int, seed int64) []Workload {
+	opts := Options{Points: points, Seed: seed}
+	jittered, sparse := opts, opts
+	jittered.Jitter = 2
+	sparse.GapRate = 0.1
+	return []Workload{
+		{"constant", opts, func() Values { return Constant(42) }},
+		{"counter", opts, func() Values { return Counter(100, 0.001) }},
+		{"random-walk", opts, func() Values { return RandomWalk(50, 1, 0.1) }},
+		{"jittered", jittered, func() Values { return RandomWalk(50, 1, 0.1) }},
+		{"sparse", sparse, func() Values { return Counter(100, 0) }},
+		{"noise", opts, func() Values { return Noise(0, 1) }},
+	}
+}
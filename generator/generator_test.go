@@ -0,0 +1,60 @@
+package generator_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/generator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Generate(t *testing.T) {
+	points := generator.Generate(generator.Options{Points: 3}, generator.Constant(1))
+	assert.Equal(t, []gorilla.Point{{T: 1600000000, V: 1}, {T: 1600000010, V: 1}, {T: 1600000020, V: 1}}, points)
+
+	opts := generator.Options{Interval: 5, Points: 1000, Jitter: 10, GapRate: 0.5, Seed: 1}
+	points = generator.Generate(opts, generator.Noise(0, 1))
+	assert.Equal(t, points, generator.Generate(opts, generator.Noise(0, 1)), "series must be reproducible")
+	assert.InDelta(t, 500, len(points), 100)
+	jittered := 0
+	for i := 1; i < len(points); i++ {
+		require.Less(t, points[i-1].T, points[i].T)
+		if (points[i].T-1600000000)%5 != 0 {
+			jittered++
+		}
+	}
+	assert.Less(t, len(points)/2, jittered)
+}
+
+func Test_Counter(t *testing.T) {
+	points := generator.Generate(generator.Options{Points: 1000, Seed: 1}, generator.Counter(10, 0.01))
+	resets := 0
+	for i := 1; i < len(points); i++ {
+		if points[i].V < points[i-1].V {
+			resets++
+		}
+	}
+	assert.Less(t, 0, resets)
+	assert.Less(t, resets, 30)
+}
+
+func Test_Workloads(t *testing.T) {
+	for _, w := range generator.Workloads(720, 1) {
+		t.Run(w.Name, func(t *testing.T) {
+			points := w.Generate()
+			require.NotEmpty(t, points)
+			buf := new(bytes.Buffer)
+			c, finish, err := gorilla.NewCompressor(buf, points[0].T)
+			require.Nil(t, err)
+			for _, p := range points {
+				require.Nil(t, c.Compress(p.T, p.V))
+			}
+			require.Nil(t, finish())
+			report, err := gorilla.Verify(buf)
+			require.Nil(t, err)
+			assert.Equal(t, len(points), report.Points)
+		})
+	}
+}
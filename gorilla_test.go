@@ -2,6 +2,7 @@ package gorilla_test
 
 import (
 	"bytes"
+	"errors"
 	"math/rand"
 	"testing"
 	"time"
@@ -58,3 +59,154 @@ func Test_Compress_Decompress(t *testing.T) {
 	require.Nil(t, iter.Err())
 	assert.Equal(t, expected, actual)
 }
+
+func Test_DecompressIterator_Err_truncated(t *testing.T) {
+	want := []point{{1600000000, 1}, {1600000060, 2}, {1600000120, 3}, {1600000180, 4}}
+	b := compress(t, 1600000000, want)
+	// Cut the block within the last point.
+	d, _, err := gorilla.NewDecompressor(bytes.NewReader(b[:len(b)-6]))
+	require.Nil(t, err)
+	var got []point
+	iter := d.Iterator()
+	for iter.Next() {
+		ts, v := iter.At()
+		got = append(got, point{ts, v})
+	}
+	assert.True(t, errors.Is(iter.Err(), gorilla.ErrTruncatedBlock), iter.Err())
+	assert.Equal(t, want[:len(got)], got)
+	assert.Less(t, len(got), len(want))
+}
+
+func Test_Compressor_Snapshot(t *testing.T) {
+	header := uint32(1600000000)
+	buf := new(bytes.Buffer)
+	c, finish, err := gorilla.NewCompressor(buf, header)
+	require.Nil(t, err)
+
+	decompress := func(b []byte) []float64 {
+		d, h, err := gorilla.NewDecompressor(bytes.NewReader(b))
+		require.Nil(t, err)
+		assert.Equal(t, header, h)
+		var vs []float64
+		iter := d.Iterator()
+		for iter.Next() {
+			_, v := iter.At()
+			vs = append(vs, v)
+		}
+		require.Nil(t, iter.Err())
+		return vs
+	}
+	snapshot := func() []float64 {
+		tail := new(bytes.Buffer)
+		require.Nil(t, c.Snapshot(tail))
+		return decompress(append(append([]byte{}, buf.Bytes()...), tail.Bytes()...))
+	}
+
+	assert.Empty(t, snapshot())
+
+	var expected []float64
+	for i := 0; i < 100; i++ {
+		v := float64(i) * 1.5
+		require.Nil(t, c.Compress(header+uint32(i*60), v))
+		expected = append(expected, v)
+		assert.Equal(t, expected, snapshot())
+	}
+
+	require.Nil(t, finish())
+	assert.Equal(t, expected, decompress(buf.Bytes()))
+}
+
+func Test_UnixSeconds(t *testing.T) {
+	for _, s := range []int64{-1, 0, 1 << 32} {
+		_, err := gorilla.UnixSeconds(s)
+		assert.True(t, errors.Is(err, gorilla.ErrOutOfRange), "%d: %v", s, err)
+	}
+	for _, s := range []int64{1, 1600000000, 1<<32 - 1} {
+		ts, err := gorilla.UnixSeconds(s)
+		require.Nil(t, err)
+		assert.Equal(t, s, int64(ts))
+	}
+
+	c, _, err := gorilla.NewCompressor(new(bytes.Buffer), 0)
+	require.Nil(t, err)
+	assert.True(t, errors.Is(c.Compress(0, 1), gorilla.ErrOutOfRange))
+}
+
+func Test_Compress_Decompress_Encoding(t *testing.T) {
+	want := []point{{1600000000, 1}, {1600000001, 1}, {1600000001, 2.5}, {1599999990, -3}, {1600086400, 1e9}}
+	for _, enc := range []gorilla.Encoding{
+		{},
+		{Unit: time.Millisecond, FirstDeltaBits: 27, Buckets: []int{14, 17, 20, 64}},
+		{Unit: time.Nanosecond, FirstDeltaBits: 63, Buckets: []int{64}},
+		{Buckets: []int{1, 2, 3, 4, 8, 16, 33}, Values: gorilla.RawValues},
+	} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c, finish, err := gorilla.NewCompressorWithEncoding(buf, 1599999000, enc)
+			require.Nil(t, err)
+			for _, p := range want {
+				require.Nil(t, c.Compress(p.t, p.v))
+			}
+			require.Nil(t, finish())
+
+			d, header, err := gorilla.NewDecompressorWithEncoding(buf, enc)
+			require.Nil(t, err)
+			assert.Equal(t, uint32(1599999000), header)
+			var got []point
+			iter := d.Iterator()
+			for iter.Next() {
+				ts, v := iter.At()
+				got = append(got, point{ts, v})
+			}
+			require.Nil(t, iter.Err())
+			assert.Equal(t, want, got)
+		})
+	}
+	assert.Equal(t, "unit=1s first-delta=14 buckets=7,9,12,32 values=xor", gorilla.Encoding{}.String())
+
+	for _, enc := range []gorilla.Encoding{
+		{Unit: -time.Second},
+		{Unit: 7 * time.Millisecond / 10},
+		{FirstDeltaBits: 64},
+		{Buckets: []int{9, 7}},
+		{Buckets: []int{0}},
+		{Values: 2},
+	} {
+		_, _, err := gorilla.NewCompressorWithEncoding(new(bytes.Buffer), 1600000000, enc)
+		assert.NotNil(t, err, enc)
+		_, _, err = gorilla.NewDecompressorWithEncoding(bytes.NewReader(make([]byte, 8)), enc)
+		assert.NotNil(t, err, enc)
+	}
+}
+
+func Test_Compressor_Compress_OutOfRange(t *testing.T) {
+	c, _, err := gorilla.NewCompressor(new(bytes.Buffer), 1600000000)
+	require.Nil(t, err)
+	err = c.Compress(1600000000+1<<14-1, 1)
+	assert.True(t, errors.Is(err, gorilla.ErrOutOfRange), err)
+
+	buf := new(bytes.Buffer)
+	c, finish, err := gorilla.NewCompressorWithEncoding(buf, 1600000000, gorilla.Encoding{Buckets: []int{8}})
+	require.Nil(t, err)
+	require.Nil(t, c.Compress(1600000000, 1))
+	require.Nil(t, c.Compress(1600000010, 2))
+	// Rejected points are not written.
+	err = c.Compress(1600000200, 3)
+	assert.EqualError(t, err, "failed to compress timestamp: timestamp out of range: delta of delta 180 exceeds 8 bits")
+	// -1 is all '1' bits in the only bucket, which is the finish marker.
+	err = c.Compress(1600000019, 3)
+	assert.True(t, errors.Is(err, gorilla.ErrOutOfRange), err)
+	require.Nil(t, c.Compress(1600000020, 3))
+	require.Nil(t, finish())
+
+	d, _, err := gorilla.NewDecompressorWithEncoding(buf, gorilla.Encoding{Buckets: []int{8}})
+	require.Nil(t, err)
+	var got []point
+	iter := d.Iterator()
+	for iter.Next() {
+		ts, v := iter.At()
+		got = append(got, point{ts, v})
+	}
+	require.Nil(t, iter.Err())
+	assert.Equal(t, []point{{1600000000, 1}, {1600000010, 2}, {1600000020, 3}}, got)
+}
@@ -0,0 +1,3 @@
+// Package graphite provides a listener of the Graphite plaintext protocol
+// appending points to gorilla-backed series.
+package graphite
@@ -0,0 +1,141 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/tsdb"
+)
+
+// Server receives lines of `path value timestamp` over TCP and appends each
+// point to the series named by its path. Timestamps are in seconds; a missing
+// or negative timestamp is replaced by the time the line is received.
+type Server struct {
+	// Appender receives the points.
+	Appender tsdb.Appender
+	// ErrorHandler is called with the errors of malformed lines and failed
+	// appends, which are skipped. It may be called concurrently. Nil ignores them.
+	ErrorHandler func(error)
+}
+
+// ListenAndServe listens on the TCP address and serves until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve accepts connections on l until ctx is done. It then closes l and the
+// connections, waits for them to be handled and returns nil. If accepting
+// fails otherwise, the connections are closed the same way and the error is returned.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	var (
+		mu      sync.Mutex
+		conns   = make(map[net.Conn]struct{})
+		closing bool
+		wg      sync.WaitGroup
+	)
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		l.Close()
+		mu.Lock()
+		closing = true
+		for c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			// Close the connections whether ctx is done or l failed, so that
+			// their handlers return.
+			close(stop)
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept: %w", err)
+		}
+		mu.Lock()
+		conns[c] = struct{}{}
+		if closing {
+			c.Close()
+		}
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(c)
+			mu.Lock()
+			delete(conns, c)
+			mu.Unlock()
+			c.Close()
+		}()
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	sc := bufio.NewScanner(c)
+	for sc.Scan() {
+		if err := s.ingest(sc.Text()); err != nil {
+			s.handleError(err)
+		}
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.handleError(fmt.Errorf("failed to read from %s: %w", c.RemoteAddr(), err))
+	}
+}
+
+func (s *Server) handleError(err error) {
+	if s.ErrorHandler != nil {
+		s.ErrorHandler(err)
+	}
+}
+
+func (s *Server) ingest(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	if 3 < len(fields) || len(fields) < 2 {
+		return fmt.Errorf("expected path value timestamp: %q", line)
+	}
+	v, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid value of %q: %w", line, err)
+	}
+	t := time.Now().Unix()
+	if len(fields) == 3 {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp of %q: %w", line, err)
+		}
+		if 0 <= ts {
+			t = int64(ts)
+		}
+	}
+	ts, err := gorilla.UnixSeconds(t)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp of %q: %w", line, err)
+	}
+	if err := s.Appender.Append(fields[0], ts, v); err != nil {
+		return fmt.Errorf("failed to append to %s: %w", fields[0], err)
+	}
+	return nil
+}
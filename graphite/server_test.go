@@ -0,0 +1,100 @@
+package graphite_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keisku/gorilla/graphite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type point struct {
+	series string
+	t      uint32
+	v      float64
+}
+
+type appender struct {
+	mu     sync.Mutex
+	points []point
+}
+
+func (a *appender) Append(series string, t uint32, v float64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.points = append(a.points, point{series, t, v})
+	return nil
+}
+
+func (a *appender) len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.points)
+}
+
+func Test_Server(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	a := &appender{}
+	var mu sync.Mutex
+	var errs []error
+	s := &graphite.Server{
+		Appender: a,
+		ErrorHandler: func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, l) }()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.Nil(t, err)
+	before := uint32(time.Now().Unix())
+	_, err = c.Write([]byte("servers.a.cpu 12.5 1600000000\nservers.a.mem 3 1600000010.7\nbroken\nservers.b.cpu x 1600000000\nservers.b.cpu 1 -1\n\n"))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool { return a.len() == 3 }, time.Second, time.Millisecond)
+
+	// An idle connection does not block the shutdown.
+	cancel()
+	require.Nil(t, <-done)
+	c.Close()
+
+	assert.Equal(t, point{"servers.a.cpu", 1600000000, 12.5}, a.points[0])
+	assert.Equal(t, point{"servers.a.mem", 1600000010, 3}, a.points[1])
+	assert.Equal(t, "servers.b.cpu", a.points[2].series)
+	assert.LessOrEqual(t, before, a.points[2].t)
+	assert.Len(t, errs, 2)
+}
+
+func Test_Server_ListenerClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	a := &appender{}
+	s := &graphite.Server{Appender: a}
+	done := make(chan error)
+	go func() { done <- s.Serve(context.Background(), l) }()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	_, err = c.Write([]byte("servers.a.cpu 12.5 1600000000\n"))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool { return a.len() == 1 }, time.Second, time.Millisecond)
+
+	// A failing listener does not wait for connected clients to disconnect.
+	require.Nil(t, l.Close())
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the listener was closed")
+	}
+}
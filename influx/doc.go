@@ -0,0 +1,2 @@
+// Package influx provides ingestion of InfluxDB line protocol into gorilla blocks.
+package influx
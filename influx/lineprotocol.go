@@ -0,0 +1,312 @@
+package influx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/tsdb"
+)
+
+// Precision is the unit of the timestamps in line protocol.
+type Precision string
+
+const (
+	Nanosecond  Precision = "ns"
+	Microsecond Precision = "us"
+	Millisecond Precision = "ms"
+	Second      Precision = "s"
+	Minute      Precision = "m"
+	Hour        Precision = "h"
+)
+
+// duration returns the duration of a unit of p.
+func (p Precision) duration() (time.Duration, error) {
+	switch p {
+	case Nanosecond, "":
+		return time.Nanosecond, nil
+	case Microsecond:
+		return time.Microsecond, nil
+	case Millisecond:
+		return time.Millisecond, nil
+	case Second:
+		return time.Second, nil
+	case Minute:
+		return time.Minute, nil
+	case Hour:
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unknown precision: %q", string(p))
+	}
+}
+
+// Options configures Ingest.
+type Options struct {
+	// Precision is the unit of the timestamps. Defaults to Nanosecond.
+	Precision Precision
+	// Now returns the timestamp of lines without one. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// LineError is an error of a line that could not be ingested.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Ingest reads line protocol from r and appends each numeric field of each
+// line to a, truncating timestamps to seconds. Each field is a series named
+// by SeriesKey. String and boolean fields are skipped.
+//
+// Lines that cannot be parsed are skipped and reported in the returned
+// LineErrors. The fields of a line are appended to separate series, so a
+// field that cannot be appended, e.g. because it is out of order, doesn't
+// keep the other fields of its line from being appended, and the line is
+// reported with the error of its first such field. The error is non-nil only
+// when reading r fails.
+func Ingest(r io.Reader, a tsdb.Appender, opts Options) ([]*LineError, error) {
+	unit, err := opts.Precision.duration()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	var lineErrs []*LineError
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		s, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return lineErrs, fmt.Errorf("failed to read line %d: %w", n, err)
+		}
+		if lineErr := ingestLine(a, s, unit, opts.Now); lineErr != nil {
+			lineErrs = append(lineErrs, &LineError{Line: n, Err: lineErr})
+		}
+		if err != nil {
+			return lineErrs, nil
+		}
+	}
+}
+
+func ingestLine(a tsdb.Appender, s string, unit time.Duration, now func() time.Time) error {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return nil
+	}
+	l, err := parseLine(s)
+	if err != nil {
+		return err
+	}
+	var t uint32
+	if l.timestamp == "" {
+		t, err = gorilla.UnixSeconds(now().Unix())
+	} else {
+		t, err = seconds(l.timestamp, unit)
+	}
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, f := range l.fields {
+		series := SeriesKey(l.measurement, l.tags, f.key)
+		if err := a.Append(series, t, f.value); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to append to %s: %w", series, err)
+		}
+	}
+	return firstErr
+}
+
+// seconds converts a timestamp in unit into seconds.
+func seconds(timestamp string, unit time.Duration) (uint32, error) {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	var s int64
+	if unit < time.Second {
+		s = ts / int64(time.Second/unit)
+	} else {
+		s = ts * int64(unit/time.Second)
+		if ts != 0 && s/ts != int64(unit/time.Second) {
+			return 0, fmt.Errorf("%w: %s", gorilla.ErrOutOfRange, timestamp)
+		}
+	}
+	return gorilla.UnixSeconds(s)
+}
+
+// Tag is a tag of a line.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// SeriesKey returns the name of the series of a field in line protocol,
+// the measurement and the tags sorted by key followed by a space and the
+// field key, e.g. `cpu,host=a,region=x usage_idle`.
+func SeriesKey(measurement string, tags []Tag, field string) string {
+	sorted := append([]Tag{}, tags...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	var sb strings.Builder
+	sb.WriteString(measurementEscaper.Replace(measurement))
+	for _, t := range sorted {
+		sb.WriteByte(',')
+		sb.WriteString(keyEscaper.Replace(t.Key))
+		sb.WriteByte('=')
+		sb.WriteString(keyEscaper.Replace(t.Value))
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(keyEscaper.Replace(field))
+	return sb.String()
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+type line struct {
+	measurement string
+	tags        []Tag
+	fields      []field
+	timestamp   string
+}
+
+type field struct {
+	key   string
+	value float64
+}
+
+// parseLine parses a line of `measurement[,tag=value...] field=value[,field=value...] [timestamp]`.
+// Only numeric fields are returned.
+func parseLine(s string) (*line, error) {
+	keyEnd := indexUnescaped(s, ' ', false)
+	if keyEnd < 0 {
+		return nil, errors.New("missing fields")
+	}
+	rest := strings.TrimLeft(s[keyEnd:], " ")
+	fieldsEnd := indexUnescaped(rest, ' ', true)
+	if fieldsEnd < 0 {
+		fieldsEnd = len(rest)
+	}
+
+	l := &line{timestamp: strings.TrimSpace(rest[fieldsEnd:])}
+	key := splitUnescaped(s[:keyEnd], ',', false)
+	l.measurement = unescape(key[0])
+	if l.measurement == "" {
+		return nil, errors.New("missing measurement")
+	}
+	for _, kv := range key[1:] {
+		k, v, err := splitKeyValue(kv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag: %w", err)
+		}
+		l.tags = append(l.tags, Tag{k, unescape(v)})
+	}
+
+	for _, kv := range splitUnescaped(rest[:fieldsEnd], ',', true) {
+		k, v, err := splitKeyValue(kv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field: %w", err)
+		}
+		value, numeric, err := parseFieldValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of field %s: %w", k, err)
+		}
+		if numeric {
+			l.fields = append(l.fields, field{k, value})
+		}
+	}
+	return l, nil
+}
+
+func splitKeyValue(kv string) (key, value string, err error) {
+	i := indexUnescaped(kv, '=', false)
+	if i <= 0 || i == len(kv)-1 {
+		return "", "", fmt.Errorf("expected key=value: %q", kv)
+	}
+	return unescape(kv[:i]), kv[i+1:], nil
+}
+
+// parseFieldValue parses a field value and reports whether it is numeric.
+func parseFieldValue(v string) (value float64, numeric bool, err error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		if len(v) < 2 || !strings.HasSuffix(v, `"`) {
+			return 0, false, errors.New("unterminated string")
+		}
+		return 0, false, nil
+	case strings.HasSuffix(v, "i"):
+		i, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		return float64(i), true, err
+	case strings.HasSuffix(v, "u"):
+		u, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+		return float64(u), true, err
+	}
+	switch v {
+	case "t", "T", "true", "True", "TRUE", "f", "F", "false", "False", "FALSE":
+		return 0, false, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false, fmt.Errorf("unsupported float: %s", v)
+	}
+	return f, true, nil
+}
+
+// indexUnescaped returns the index of the first c in s not escaped by a
+// backslash, nor in a double-quoted string when quoted is true, or -1.
+func indexUnescaped(s string, c byte, quoted bool) int {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quoted && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == c && !inQuotes:
+			return i
+		}
+	}
+	return -1
+}
+
+func splitUnescaped(s string, sep byte, quoted bool) []string {
+	var parts []string
+	for {
+		i := indexUnescaped(s, sep, quoted)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+1:]
+	}
+}
+
+// unescape removes the backslashes escaping commas, equal signs and spaces.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return unescaper.Replace(s)
+}
+
+var unescaper = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ")
@@ -0,0 +1,133 @@
+package influx_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keisku/gorilla/influx"
+	"github.com/keisku/gorilla/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type point struct {
+	series string
+	t      uint32
+	v      float64
+}
+
+type appender struct {
+	points []point
+}
+
+func (a *appender) Append(series string, t uint32, v float64) error {
+	if 0 < len(a.points) && t < a.points[len(a.points)-1].t {
+		return tsdb.ErrOutOfOrder
+	}
+	a.points = append(a.points, point{series, t, v})
+	return nil
+}
+
+func Test_Ingest(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"cpu,region=x,host=a usage_idle=90.5,usage_user=3i 1600000000000000000",
+		"",
+		`cpu,host=a,region=x usage_idle=91,msg="a, b=c \"d\"",ok=true 1600000010000000000`,
+		`disk\ io,path=C:\,\ x free=10u 1600000020000000000`,
+		"mem free=1 bad",
+		"mem",
+		"mem,host free=1",
+		"mem free=1 1500000000000000000",
+		"mem free=\"x",
+		"mem free=1x 1600000030000000000",
+	}, "\n")
+
+	a := &appender{}
+	lineErrs, err := influx.Ingest(strings.NewReader(input), a, influx.Options{})
+	require.Nil(t, err)
+
+	assert.Equal(t, []point{
+		{"cpu,host=a,region=x usage_idle", 1600000000, 90.5},
+		{"cpu,host=a,region=x usage_user", 1600000000, 3},
+		{"cpu,host=a,region=x usage_idle", 1600000010, 91},
+		{`disk\ io,path=C:\,\ x free`, 1600000020, 10},
+	}, a.points)
+
+	var lines []int
+	for _, e := range lineErrs {
+		lines = append(lines, e.Line)
+	}
+	assert.Equal(t, []int{6, 7, 8, 9, 10, 11}, lines)
+	assert.True(t, errors.Is(lineErrs[3], tsdb.ErrOutOfOrder))
+}
+
+func Test_Ingest_Precision(t *testing.T) {
+	tests := []struct {
+		precision influx.Precision
+		timestamp string
+	}{
+		{influx.Nanosecond, "1600000000123456789"},
+		{influx.Microsecond, "1600000000123456"},
+		{influx.Millisecond, "1600000000123"},
+		{influx.Second, "1600000000"},
+		{influx.Minute, "26666666"},
+		{influx.Hour, "444444"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.precision), func(t *testing.T) {
+			a := &appender{}
+			lineErrs, err := influx.Ingest(strings.NewReader("m v=1 "+tt.timestamp), a, influx.Options{Precision: tt.precision})
+			require.Nil(t, err)
+			require.Empty(t, lineErrs)
+			require.Len(t, a.points, 1)
+			want := map[influx.Precision]uint32{influx.Minute: 26666666 * 60, influx.Hour: 444444 * 3600}[tt.precision]
+			if want == 0 {
+				want = 1600000000
+			}
+			assert.Equal(t, want, a.points[0].t)
+		})
+	}
+
+	_, err := influx.Ingest(strings.NewReader(""), &appender{}, influx.Options{Precision: "d"})
+	assert.NotNil(t, err)
+}
+
+func Test_Ingest_Now(t *testing.T) {
+	a := &appender{}
+	now := time.Unix(1600000000, 0)
+	lineErrs, err := influx.Ingest(strings.NewReader("m v=1\n"), a, influx.Options{Now: func() time.Time { return now }})
+	require.Nil(t, err)
+	require.Empty(t, lineErrs)
+	assert.Equal(t, []point{{"m v", 1600000000, 1}}, a.points)
+
+	// A time beyond the uint32 seconds of blocks is rejected rather than wrapped.
+	now = time.Unix(1<<32+1600000000, 0)
+	lineErrs, err = influx.Ingest(strings.NewReader("m v=2\n"), a, influx.Options{Now: func() time.Time { return now }})
+	require.Nil(t, err)
+	require.Len(t, lineErrs, 1)
+	assert.True(t, errors.Is(lineErrs[0], tsdb.ErrOutOfRange))
+	assert.Equal(t, []point{{"m v", 1600000000, 1}}, a.points)
+}
+
+func Test_Ingest_PartialLine(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	input := "m b=1 1600000060\nm b=2,a=3 1600000000\n"
+	lineErrs, err := influx.Ingest(strings.NewReader(input), s, influx.Options{Precision: influx.Second})
+	require.Nil(t, err)
+	require.Len(t, lineErrs, 1)
+	assert.Equal(t, 2, lineErrs[0].Line)
+	assert.True(t, errors.Is(lineErrs[0], tsdb.ErrOutOfOrder))
+
+	// The field after the out of order one is appended.
+	it := s.Query("m a", 0, 1<<32-1)
+	require.True(t, it.Next())
+	ts, v := it.At()
+	assert.Equal(t, uint32(1600000000), ts)
+	assert.Equal(t, float64(3), v)
+	assert.False(t, it.Next())
+	require.Nil(t, it.Err())
+}
@@ -0,0 +1,146 @@
+// Package wire provides helpers to marshal and unmarshal protobuf messages
+// field by field, for the few messages of the protocols this module speaks.
+package wire
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field is a field of a protobuf message.
+type Field struct {
+	Num  protowire.Number
+	Type protowire.Type
+	// U is the value of a varint or fixed field.
+	U uint64
+	// B is the value of a length-delimited field.
+	B []byte
+}
+
+// ErrType returns the error of f having an unexpected wire type.
+func ErrType(f Field) error {
+	return fmt.Errorf("invalid wire type %d of field %d", f.Type, f.Num)
+}
+
+// Message unmarshals the embedded message f.
+func (f Field) Message(unmarshal func([]byte) error) error {
+	if f.Type != protowire.BytesType {
+		return ErrType(f)
+	}
+	return unmarshal(f.B)
+}
+
+// String sets s to the string f.
+func (f Field) String(s *string) error {
+	if f.Type != protowire.BytesType {
+		return ErrType(f)
+	}
+	*s = string(f.B)
+	return nil
+}
+
+// Int64 sets i to the varint f.
+func (f Field) Int64(i *int64) error {
+	if f.Type != protowire.VarintType {
+		return ErrType(f)
+	}
+	*i = int64(f.U)
+	return nil
+}
+
+// Fixed64 sets u to the fixed64 f.
+func (f Field) Fixed64(u *uint64) error {
+	if f.Type != protowire.Fixed64Type {
+		return ErrType(f)
+	}
+	*u = f.U
+	return nil
+}
+
+// PackedVarints calls fn for each value of the repeated varint f,
+// either packed or not.
+func (f Field) PackedVarints(fn func(uint64)) error {
+	switch f.Type {
+	case protowire.VarintType:
+		fn(f.U)
+		return nil
+	case protowire.BytesType:
+		b := f.B
+		for 0 < len(b) {
+			u, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(u)
+			b = b[n:]
+		}
+		return nil
+	default:
+		return ErrType(f)
+	}
+}
+
+// UnmarshalFields calls fn for each field of the protobuf message b.
+func UnmarshalFields(b []byte, fn func(Field) error) error {
+	for 0 < len(b) {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := Field{Num: num, Type: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.U, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.U, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var u uint32
+			u, n = protowire.ConsumeFixed32(b)
+			f.U = uint64(u)
+		case protowire.BytesType:
+			f.B, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendMessage appends the embedded message m as the field num to b.
+func AppendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// AppendBytes appends the bytes v as the field num to b.
+func AppendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// AppendString appends s as the field num to b.
+func AppendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// AppendVarint appends the varint u as the field num to b.
+func AppendVarint(b []byte, num protowire.Number, u uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, u)
+}
+
+// AppendFixed64 appends the fixed64 u as the field num to b.
+func AppendFixed64(b []byte, num protowire.Number, u uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, u)
+}
@@ -0,0 +1,131 @@
+package xorchunk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errShortChunk = errors.New("chunk is too short")
+
+// Decode decodes the samples of chunk b, calling fn with each of them.
+func Decode(b []byte, fn func(t int64, v float64)) error {
+	if len(b) < 2 {
+		return errShortChunk
+	}
+	num := int(binary.BigEndian.Uint16(b))
+	r := bitReader{b: b[2:]}
+	var (
+		t        int64
+		tDelta   uint64
+		v        uint64
+		leading  uint8
+		trailing uint8
+	)
+	for i := 0; i < num; i++ {
+		switch i {
+		case 0:
+			var err error
+			if t, err = binary.ReadVarint(&r); err != nil {
+				return fmt.Errorf("sample %d: failed to read timestamp: %w", i, err)
+			}
+			if v, err = r.readBits(64); err != nil {
+				return fmt.Errorf("sample %d: failed to read value: %w", i, err)
+			}
+			fn(t, math.Float64frombits(v))
+			continue
+		case 1:
+			var err error
+			if tDelta, err = binary.ReadUvarint(&r); err != nil {
+				return fmt.Errorf("sample %d: failed to read timestamp: %w", i, err)
+			}
+		default:
+			dod, err := r.readDod()
+			if err != nil {
+				return fmt.Errorf("sample %d: failed to read timestamp: %w", i, err)
+			}
+			tDelta = uint64(int64(tDelta) + dod)
+		}
+		t += int64(tDelta)
+
+		xor, err := r.readBits(1)
+		if err == nil && xor == 1 {
+			var window uint64
+			if window, err = r.readBits(1); err == nil && window == 1 {
+				var bits uint64
+				if bits, err = r.readBits(5 + 6); err == nil {
+					significant := uint8(bits & 0x3F)
+					if significant == 0 {
+						significant = 64
+					}
+					leading = uint8(bits >> 6)
+					if 64 < leading+significant {
+						return fmt.Errorf("sample %d: invalid meaningful bits: %d leading zeros and %d significant bits", i, leading, significant)
+					}
+					trailing = 64 - leading - significant
+				}
+			}
+			if err == nil {
+				if xor, err = r.readBits(int(64 - leading - trailing)); err == nil {
+					v ^= xor << trailing
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("sample %d: failed to read value: %w", i, err)
+		}
+		fn(t, math.Float64frombits(v))
+	}
+	return nil
+}
+
+type bitReader struct {
+	b []byte
+	// n is the number of bits read.
+	n int
+}
+
+func (r *bitReader) readBits(nbits int) (uint64, error) {
+	if len(r.b)*8 < r.n+nbits {
+		return 0, errShortChunk
+	}
+	var u uint64
+	for i := 0; i < nbits; i++ {
+		u = u<<1 | uint64(r.b[r.n/8]>>(7-r.n%8)&1)
+		r.n++
+	}
+	return u, nil
+}
+
+// ReadByte reads 8 bits to read varints.
+func (r *bitReader) ReadByte() (byte, error) {
+	u, err := r.readBits(8)
+	return byte(u), err
+}
+
+func (r *bitReader) readDod() (int64, error) {
+	var control int
+	for ; control < 4; control++ {
+		bit, err := r.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			break
+		}
+	}
+	sz := []int{0, 14, 17, 20, 64}[control]
+	if sz == 0 {
+		return 0, nil
+	}
+	bits, err := r.readBits(sz)
+	if err != nil {
+		return 0, err
+	}
+	dod := int64(bits)
+	if sz != 64 && 1<<(sz-1) < bits {
+		dod -= 1 << sz
+	}
+	return dod, nil
+}
@@ -0,0 +1,154 @@
+// Package xorchunk encodes and decodes samples in the XOR chunk encoding of
+// Prometheus, which differs from gorilla blocks in its millisecond timestamp
+// buckets and framing.
+package xorchunk
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// MaxSamples is the maximum number of samples of a chunk.
+const MaxSamples = math.MaxUint16
+
+// Encoder encodes samples into a chunk.
+type Encoder struct {
+	b        []byte
+	count    uint8 // number of bits free in the last byte of b
+	num      uint16
+	t        int64
+	tDelta   uint64
+	v        float64
+	leading  uint8
+	trailing uint8
+}
+
+// NewEncoder returns an encoder of an empty chunk.
+func NewEncoder() *Encoder {
+	return &Encoder{b: make([]byte, 2), leading: 0xff}
+}
+
+// Len returns the number of samples encoded.
+func (c *Encoder) Len() int {
+	return int(c.num)
+}
+
+// Bytes returns the encoded chunk starting with the big-endian number of samples.
+func (c *Encoder) Bytes() []byte {
+	binary.BigEndian.PutUint16(c.b, c.num)
+	return c.b
+}
+
+// Append encodes a sample of t in milliseconds. Timestamps must be increasing
+// and at most MaxSamples samples can be appended.
+func (c *Encoder) Append(t int64, v float64) {
+	var tDelta uint64
+	var buf [binary.MaxVarintLen64]byte
+	switch c.num {
+	case 0:
+		for _, b := range buf[:binary.PutVarint(buf[:], t)] {
+			c.writeByte(b)
+		}
+		c.writeBits(math.Float64bits(v), 64)
+	case 1:
+		tDelta = uint64(t - c.t)
+		for _, b := range buf[:binary.PutUvarint(buf[:], tDelta)] {
+			c.writeByte(b)
+		}
+		c.writeValue(v)
+	default:
+		tDelta = uint64(t - c.t)
+		dod := int64(tDelta - c.tDelta)
+		switch {
+		case dod == 0:
+			c.writeBits(0, 1)
+		case bitRange(dod, 14):
+			c.writeBits(0x02, 2)
+			c.writeBits(uint64(dod), 14)
+		case bitRange(dod, 17):
+			c.writeBits(0x06, 3)
+			c.writeBits(uint64(dod), 17)
+		case bitRange(dod, 20):
+			c.writeBits(0x0E, 4)
+			c.writeBits(uint64(dod), 20)
+		default:
+			c.writeBits(0x0F, 4)
+			c.writeBits(uint64(dod), 64)
+		}
+		c.writeValue(v)
+	}
+	c.t = t
+	c.v = v
+	c.tDelta = tDelta
+	c.num++
+}
+
+func bitRange(x int64, nbits uint8) bool {
+	return -((1<<(nbits-1))-1) <= x && x <= 1<<(nbits-1)
+}
+
+func (c *Encoder) writeValue(v float64) {
+	xor := math.Float64bits(v) ^ math.Float64bits(c.v)
+	if xor == 0 {
+		c.writeBits(0, 1)
+		return
+	}
+	c.writeBits(1, 1)
+
+	leading := uint8(bits.LeadingZeros64(xor))
+	trailing := uint8(bits.TrailingZeros64(xor))
+	// Leading zeros are written in 5 bits.
+	if 32 <= leading {
+		leading = 31
+	}
+	if c.leading != 0xff && c.leading <= leading && c.trailing <= trailing {
+		c.writeBits(0, 1)
+		c.writeBits(xor>>c.trailing, 64-int(c.leading)-int(c.trailing))
+		return
+	}
+	c.leading, c.trailing = leading, trailing
+	c.writeBits(1, 1)
+	c.writeBits(uint64(leading), 5)
+	// 64 significant bits are written as 0 since they do not fit in 6 bits.
+	significantBits := 64 - leading - trailing
+	c.writeBits(uint64(significantBits), 6)
+	c.writeBits(xor>>trailing, int(significantBits))
+}
+
+// writeBits writes the nbits right-most bits of u in left-to-right order.
+func (c *Encoder) writeBits(u uint64, nbits int) {
+	u <<= 64 - uint(nbits)
+	for 8 <= nbits {
+		c.writeByte(byte(u >> 56))
+		u <<= 8
+		nbits -= 8
+	}
+	for 0 < nbits {
+		c.writeBit(u>>63 == 1)
+		u <<= 1
+		nbits--
+	}
+}
+
+func (c *Encoder) writeBit(bit bool) {
+	if c.count == 0 {
+		c.b = append(c.b, 0)
+		c.count = 8
+	}
+	if bit {
+		c.b[len(c.b)-1] |= 1 << (c.count - 1)
+	}
+	c.count--
+}
+
+// writeByte writes a byte like Prometheus does, which always appends a byte
+// for the bits not fitting in the last one, even if there are none.
+func (c *Encoder) writeByte(byt byte) {
+	if c.count == 0 {
+		c.b = append(c.b, 0)
+		c.count = 8
+	}
+	c.b[len(c.b)-1] |= byt >> (8 - c.count)
+	c.b = append(c.b, byt<<c.count)
+}
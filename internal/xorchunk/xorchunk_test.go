@@ -0,0 +1,68 @@
+package xorchunk_test
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/keisku/gorilla/internal/xorchunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sample struct {
+	t int64
+	v float64
+}
+
+var samples = []sample{
+	{1600000000000, 1},
+	{1600000015000, 1},
+	{1600000030000, 2.5},
+	{1600000045000, 2.5},
+	{1600000061000, -3},
+	{1600000062000, 1e9},
+}
+
+// Encoded by chunkenc.XORChunk of Prometheus v2.45.0.
+const golden = "00068080f4f6905d3ff00000000000009875309bffd20fa301d001ee2b46082071735940"
+
+func Test_Encoder(t *testing.T) {
+	c := xorchunk.NewEncoder()
+	for _, s := range samples {
+		c.Append(s.t, s.v)
+	}
+	assert.Equal(t, len(samples), c.Len())
+	assert.Equal(t, golden, hex.EncodeToString(c.Bytes()))
+}
+
+func Test_Decode(t *testing.T) {
+	b, err := hex.DecodeString(golden)
+	require.Nil(t, err)
+	var got []sample
+	require.Nil(t, xorchunk.Decode(b, func(t int64, v float64) {
+		got = append(got, sample{t, v})
+	}))
+	assert.Equal(t, samples, got)
+
+	assert.NotNil(t, xorchunk.Decode(b[:len(b)-3], func(int64, float64) {}))
+	assert.NotNil(t, xorchunk.Decode(b[:1], func(int64, float64) {}))
+}
+
+func Test_roundTrip(t *testing.T) {
+	c := xorchunk.NewEncoder()
+	var want []sample
+	ts := int64(1600000000000)
+	// The deltas of delta cover every bucket in both signs.
+	for i, delta := range []int64{15000, 15000, 15001, 7000, 15191, 80727, 15000, 539288, 1 << 41, 15000} {
+		ts += delta
+		v := math.Sin(float64(i)) * 1000
+		want = append(want, sample{ts, v})
+		c.Append(ts, v)
+	}
+	var got []sample
+	require.Nil(t, xorchunk.Decode(c.Bytes(), func(t int64, v float64) {
+		got = append(got, sample{t, v})
+	}))
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,145 @@
+package gorilla
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+type jsonBlock struct {
+	Header  uint32 `json:"header"`
+	Count   int    `json:"count"`
+	MinTime uint32 `json:"minTime"`
+	MaxTime uint32 `json:"maxTime"`
+	Data    []byte `json:"data"`
+}
+
+// MarshalJSON encodes b as an object of its stats and its base64-encoded data:
+//
+//	{"header":1600000000,"count":2,"minTime":1600000000,"maxTime":1600000060,"data":"..."}
+func (b Block) MarshalJSON() ([]byte, error) {
+	stats, err := b.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonBlock{stats.Header, stats.Count, stats.MinTime, stats.MaxTime, b})
+}
+
+// UnmarshalJSON decodes the data of an object encoded by MarshalJSON into b.
+// It fails if the data cannot be decompressed or does not match the stats.
+func (b *Block) UnmarshalJSON(data []byte) error {
+	var jb jsonBlock
+	if err := json.Unmarshal(data, &jb); err != nil {
+		return err
+	}
+	stats, err := Block(jb.Data).Stats()
+	if err != nil {
+		return fmt.Errorf("invalid block data: %w", err)
+	}
+	if stats != (BlockStats{jb.Header, jb.Count, jb.MinTime, jb.MaxTime}) {
+		return fmt.Errorf("block data does not match stats: %+v", stats)
+	}
+	*b = jb.Data
+	return nil
+}
+
+// EncodeJSON writes the points of iter to w as an array of [t, v] pairs.
+// Since JSON has no representation of NaN and infinities, they are encoded
+// as the strings "NaN", "+Inf" and "-Inf", while the others are numbers:
+//
+//	[[1600000000,1.5],[1600000060,"NaN"]]
+//
+// Points are written as they are decompressed.
+func EncodeJSON(w io.Writer, iter *DecompressIterator) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '[')
+	for n := 0; iter.Next(); n++ {
+		t, v := iter.At()
+		if 0 < n {
+			buf = append(buf, ',')
+		}
+		buf = Point{t, v}.appendJSON(buf)
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+		buf = buf[:0]
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+	buf = append(buf, ']')
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Point is a point of a series, encoded in JSON as [t, v] following the
+// convention of EncodeJSON.
+type Point struct {
+	T uint32
+	V float64
+}
+
+// MarshalJSON encodes p as [t, v].
+func (p Point) MarshalJSON() ([]byte, error) {
+	return p.appendJSON(nil), nil
+}
+
+func (p Point) appendJSON(b []byte) []byte {
+	b = append(b, '[')
+	b = strconv.AppendUint(b, uint64(p.T), 10)
+	b = append(b, ',')
+	b = appendJSONFloat(b, p.V)
+	return append(b, ']')
+}
+
+// UnmarshalJSON decodes [t, v] into p, where v is a number or one of the
+// strings "NaN", "+Inf" and "-Inf".
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("point must be [t, v], got %s", data)
+	}
+	var t uint32
+	if err := json.Unmarshal(pair[0], &t); err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	var v float64
+	var s string
+	if err := json.Unmarshal(pair[1], &s); err == nil {
+		switch s {
+		case "NaN":
+			v = math.NaN()
+		case "+Inf":
+			v = math.Inf(1)
+		case "-Inf":
+			v = math.Inf(-1)
+		default:
+			return fmt.Errorf("invalid value: %q", s)
+		}
+	} else if err := json.Unmarshal(pair[1], &v); err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+	p.T, p.V = t, v
+	return nil
+}
+
+func appendJSONFloat(b []byte, v float64) []byte {
+	switch {
+	case math.IsNaN(v):
+		return append(b, `"NaN"`...)
+	case math.IsInf(v, 1):
+		return append(b, `"+Inf"`...)
+	case math.IsInf(v, -1):
+		return append(b, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(b, v, 'g', -1, 64)
+}
@@ -0,0 +1,94 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Block_JSON(t *testing.T) {
+	b := gorilla.Block(compress(t, 1600000000, series(1600000010, 3, 60, 1)))
+	data, err := json.Marshal(b)
+	require.Nil(t, err)
+
+	var got map[string]interface{}
+	require.Nil(t, json.Unmarshal(data, &got))
+	assert.Equal(t, float64(1600000000), got["header"])
+	assert.Equal(t, float64(3), got["count"])
+	assert.Equal(t, float64(1600000010), got["minTime"])
+	assert.Equal(t, float64(1600000130), got["maxTime"])
+
+	var decoded gorilla.Block
+	require.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, b, decoded)
+
+	got["count"] = 4
+	data, err = json.Marshal(got)
+	require.Nil(t, err)
+	assert.NotNil(t, json.Unmarshal(data, &decoded))
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"data":"AAA="}`), &decoded))
+}
+
+func Test_EncodeJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []point
+		want   string
+	}{
+		{"empty", nil, `[]`},
+		{
+			name:   "numbers",
+			points: []point{{1600000000, 1.5}, {1600000060, -2}, {1600000120, 1e21}, {1600000180, 1e-7}},
+			want:   `[[1600000000,1.5],[1600000060,-2],[1600000120,1e+21],[1600000180,1e-07]]`,
+		},
+		{
+			name:   "non-finite",
+			points: []point{{1600000000, math.NaN()}, {1600000060, math.Inf(1)}, {1600000120, math.Inf(-1)}},
+			want:   `[[1600000000,"NaN"],[1600000060,"+Inf"],[1600000120,"-Inf"]]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _, err := gorilla.NewDecompressor(bytes.NewReader(compress(t, 1600000000, tt.points)))
+			require.Nil(t, err)
+			var buf bytes.Buffer
+			require.Nil(t, gorilla.EncodeJSON(&buf, d.Iterator()))
+			assert.Equal(t, tt.want, buf.String())
+			assert.True(t, json.Valid(buf.Bytes()))
+		})
+	}
+}
+
+func Test_Point_JSON(t *testing.T) {
+	tests := []struct {
+		json  string
+		point gorilla.Point
+	}{
+		{`[1600000000,1.5]`, gorilla.Point{T: 1600000000, V: 1.5}},
+		{`[1600000000,"+Inf"]`, gorilla.Point{T: 1600000000, V: math.Inf(1)}},
+		{`[1600000000,"-Inf"]`, gorilla.Point{T: 1600000000, V: math.Inf(-1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			b, err := json.Marshal(tt.point)
+			require.Nil(t, err)
+			assert.Equal(t, tt.json, string(b))
+			var p gorilla.Point
+			require.Nil(t, json.Unmarshal(b, &p))
+			assert.Equal(t, tt.point, p)
+		})
+	}
+
+	var p gorilla.Point
+	require.Nil(t, json.Unmarshal([]byte(`[1600000000, "NaN"]`), &p))
+	assert.True(t, math.IsNaN(p.V))
+	for _, invalid := range []string{`[1]`, `{"t":1}`, `[-1,1]`, `[1,"x"]`, `[1,true]`} {
+		assert.NotNil(t, json.Unmarshal([]byte(invalid), &p), invalid)
+	}
+}
@@ -0,0 +1,3 @@
+// Package otlp provides an OTLP/HTTP receiver storing OpenTelemetry gauges
+// and sums into gorilla blocks.
+package otlp
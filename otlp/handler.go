@@ -0,0 +1,148 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/internal/wire"
+	"github.com/keisku/gorilla/remote"
+	"github.com/keisku/gorilla/tsdb"
+)
+
+// Handler receives OTLP/HTTP ExportMetricsServiceRequests in protobuf and
+// appends the data points of gauges and sums to a, with their timestamps
+// truncated to seconds. Each data point is appended to the series named by
+// remote.SeriesName from the metric name and the attributes of its resource,
+// scope and itself, where an attribute overrides the same-named ones of the
+// levels above.
+//
+// Data points of other metric types, without value or timestamp, or out of
+// order are rejected and reported in a partial success response. It responds
+// 400 when the request is malformed or its body is larger than 32 MiB before
+// or after it is decompressed, 415 when it is not protobuf, and 503 when the
+// appender fails otherwise.
+type Handler struct {
+	appender tsdb.Appender
+}
+
+// maxBodySize is the largest size in bytes of a request body, both before
+// and after it is decompressed.
+const maxBodySize = 32 << 20
+
+// NewHandler returns a Handler appending data points to a.
+func NewHandler(a tsdb.Appender) *Handler {
+	return &Handler{appender: a}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/x-protobuf" {
+		http.Error(w, "unsupported content type: "+mediaType, http.StatusUnsupportedMediaType)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	switch enc := r.Header.Get("Content-Encoding"); enc {
+	case "":
+	case "gzip":
+		gr, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gr.Close()
+		body = gr
+	default:
+		http.Error(w, "unsupported content encoding: "+enc, http.StatusUnsupportedMediaType)
+		return
+	}
+	b, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if maxBodySize < len(b) {
+		http.Error(w, fmt.Sprintf("decompressed body is larger than %d bytes", maxBodySize), http.StatusBadRequest)
+		return
+	}
+	rms, err := unmarshalRequest(b)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to unmarshal body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	rejected := make(map[string]int64)
+	for _, rm := range rms {
+		for _, sm := range rm.scopes {
+			for _, m := range sm.metrics {
+				if m.kind != "gauge" && m.kind != "sum" {
+					rejected["unsupported metric type "+m.kind] += int64(len(m.points))
+					continue
+				}
+				for _, p := range m.points {
+					labels := mergeLabels(rm.attributes, sm.attributes, p.attributes,
+						[]remote.Label{{Name: remote.MetricName, Value: m.name}})
+					reason, err := h.append(remote.SeriesName(labels), p)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusServiceUnavailable)
+						return
+					}
+					if reason != "" {
+						rejected[reason]++
+					}
+				}
+			}
+		}
+	}
+
+	var resp []byte
+	if 0 < len(rejected) {
+		resp = wire.AppendMessage(nil, 1, partialSuccess(rejected))
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(resp)
+}
+
+// append appends p to the series, returning why p is rejected if it is.
+func (h *Handler) append(series string, p dataPoint) (reason string, err error) {
+	if !p.hasValue {
+		return "data point without value", nil
+	}
+	t, err := gorilla.UnixSeconds(int64(p.timeUnixNano / 1e9))
+	if err == nil {
+		err = h.appender.Append(series, t, p.value)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, tsdb.ErrOutOfRange):
+			return "timestamp out of range", nil
+		case errors.Is(err, tsdb.ErrOutOfOrder):
+			return "out of order data point", nil
+		}
+		return "", fmt.Errorf("failed to append to %s: %w", series, err)
+	}
+	return "", nil
+}
+
+// partialSuccess returns an ExportMetricsPartialSuccess of the rejected data points by reason.
+func partialSuccess(rejected map[string]int64) []byte {
+	var total int64
+	reasons := make([]string, 0, len(rejected))
+	for reason, n := range rejected {
+		total += n
+		reasons = append(reasons, fmt.Sprintf("%s (%d)", reason, n))
+	}
+	sort.Strings(reasons)
+	b := wire.AppendVarint(nil, 1, uint64(total))
+	return wire.AppendString(b, 2, "rejected data points: "+strings.Join(reasons, ", "))
+}
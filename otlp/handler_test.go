@@ -0,0 +1,203 @@
+package otlp_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keisku/gorilla/internal/wire"
+	"github.com/keisku/gorilla/otlp"
+	"github.com/keisku/gorilla/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type point struct {
+	t uint32
+	v float64
+}
+
+func query(t *testing.T, s *tsdb.Store, series string) []point {
+	t.Helper()
+	var points []point
+	it := s.Query(series, 0, 1<<32-1)
+	for it.Next() {
+		ts, v := it.At()
+		points = append(points, point{ts, v})
+	}
+	require.Nil(t, it.Err())
+	return points
+}
+
+func attribute(key, value string) []byte {
+	return wire.AppendMessage(wire.AppendString(nil, 1, key), 2, wire.AppendString(nil, 1, value))
+}
+
+func doublePoint(sec uint64, v float64, attrs ...[]byte) []byte {
+	b := wire.AppendFixed64(nil, 3, sec*1e9)
+	b = wire.AppendFixed64(b, 4, math.Float64bits(v))
+	for _, a := range attrs {
+		b = wire.AppendMessage(b, 7, a)
+	}
+	return b
+}
+
+func intPoint(sec uint64, v int64) []byte {
+	b := wire.AppendFixed64(nil, 3, sec*1e9)
+	return wire.AppendFixed64(b, 6, uint64(v))
+}
+
+func metric(name string, kind protowire.Number, points ...[]byte) []byte {
+	var data []byte
+	for _, p := range points {
+		data = wire.AppendMessage(data, 1, p)
+	}
+	return wire.AppendMessage(wire.AppendString(nil, 1, name), kind, data)
+}
+
+func request(resource, scope []byte, metrics ...[]byte) []byte {
+	sm := wire.AppendMessage(nil, 1, scope)
+	for _, m := range metrics {
+		sm = wire.AppendMessage(sm, 2, m)
+	}
+	rm := wire.AppendMessage(nil, 1, resource)
+	rm = wire.AppendMessage(rm, 2, sm)
+	return wire.AppendMessage(nil, 1, rm)
+}
+
+func post(t *testing.T, url, contentType string, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := http.Post(url, contentType, bytes.NewReader(body))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.Nil(t, err)
+	return resp, b
+}
+
+func Test_Handler(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	srv := httptest.NewServer(otlp.NewHandler(s))
+	defer srv.Close()
+
+	resource := wire.AppendMessage(nil, 1, attribute("service.name", "api"))
+	scope := wire.AppendString(wire.AppendString(nil, 1, "meter"), 2, "1.0")
+	body := request(resource, scope,
+		metric("temperature", 5,
+			doublePoint(1600000000, 21.5, attribute("room", "a")),
+			doublePoint(1600000060, 22, attribute("room", "a"))),
+		metric("requests", 7, intPoint(1600000000, 3), intPoint(1600000060, 5)),
+		metric("latency", 9, []byte{}, []byte{}),
+	)
+	resp, b := post(t, srv.URL, "application/x-protobuf", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-protobuf", resp.Header.Get("Content-Type"))
+
+	assert.Equal(t, []point{{1600000000, 21.5}, {1600000060, 22}},
+		query(t, s, `temperature{otel_scope_name="meter",otel_scope_version="1.0",room="a",service.name="api"}`))
+	assert.Equal(t, []point{{1600000000, 3}, {1600000060, 5}},
+		query(t, s, `requests{otel_scope_name="meter",otel_scope_version="1.0",service.name="api"}`))
+
+	var rejected int64
+	var message string
+	require.Nil(t, wire.UnmarshalFields(b, func(f wire.Field) error {
+		return f.Message(func(b []byte) error {
+			return wire.UnmarshalFields(b, func(f wire.Field) error {
+				switch f.Num {
+				case 1:
+					return f.Int64(&rejected)
+				case 2:
+					return f.String(&message)
+				}
+				return nil
+			})
+		})
+	}))
+	assert.Equal(t, int64(2), rejected)
+	assert.Contains(t, message, "unsupported metric type histogram (2)")
+}
+
+func Test_Handler_partialSuccess(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	srv := httptest.NewServer(otlp.NewHandler(s))
+	defer srv.Close()
+
+	body := request(nil, nil, metric("up", 5,
+		doublePoint(1600000060, 1),
+		doublePoint(1600000000, 1),
+		doublePoint(0, 1),
+		wire.AppendFixed64(nil, 3, 1600000120*1e9),
+	))
+	resp, b := post(t, srv.URL, "application/x-protobuf", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(b), "out of order data point (1)")
+	assert.Contains(t, string(b), "timestamp out of range (1)")
+	assert.Contains(t, string(b), "data point without value (1)")
+	assert.Equal(t, []point{{1600000060, 1}}, query(t, s, "up"))
+
+	resp, b = post(t, srv.URL, "application/x-protobuf", request(nil, nil, metric("up", 5, doublePoint(1600000120, 1))))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, b)
+}
+
+func Test_Handler_gzip(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	srv := httptest.NewServer(otlp.NewHandler(s))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(request(nil, nil, metric("up", 5, doublePoint(1600000000, 1))))
+	require.Nil(t, err)
+	require.Nil(t, zw.Close())
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, &buf)
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []point{{1600000000, 1}}, query(t, s, "up"))
+
+	// A body decompressed into more than 32 MiB is rejected.
+	buf.Reset()
+	zw = gzip.NewWriter(&buf)
+	_, err = zw.Write(make([]byte, 33<<20))
+	require.Nil(t, err)
+	require.Nil(t, zw.Close())
+	req, err = http.NewRequest(http.MethodPost, srv.URL, &buf)
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func Test_Handler_badRequest(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	srv := httptest.NewServer(otlp.NewHandler(s))
+	defer srv.Close()
+
+	resp, _ := post(t, srv.URL, "application/x-protobuf", []byte{0x0a, 0xff})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = post(t, srv.URL, "application/json", []byte("{}"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
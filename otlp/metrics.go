@@ -0,0 +1,332 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/keisku/gorilla/internal/wire"
+	"github.com/keisku/gorilla/remote"
+)
+
+// Labels added for the instrumentation scope of a metric, as the
+// Prometheus exporter of OpenTelemetry does.
+const (
+	ScopeNameLabel    = "otel_scope_name"
+	ScopeVersionLabel = "otel_scope_version"
+)
+
+// The messages below mirror the ones of ExportMetricsServiceRequest with
+// the fields this package uses. Unknown fields are skipped when unmarshaling.
+
+type resourceMetrics struct {
+	attributes []remote.Label
+	scopes     []scopeMetrics
+}
+
+type scopeMetrics struct {
+	attributes []remote.Label
+	metrics    []metric
+}
+
+type metric struct {
+	name string
+	// kind is the name of the data field of the metric.
+	kind   string
+	points []dataPoint
+}
+
+type dataPoint struct {
+	attributes   []remote.Label
+	timeUnixNano uint64
+	value        float64
+	hasValue     bool
+}
+
+// Field numbers of the data of Metric.
+var metricKinds = map[uint64]string{
+	5:  "gauge",
+	7:  "sum",
+	9:  "histogram",
+	10: "exponential_histogram",
+	11: "summary",
+}
+
+func unmarshalRequest(b []byte) ([]resourceMetrics, error) {
+	var rms []resourceMetrics
+	err := wire.UnmarshalFields(b, func(f wire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		var rm resourceMetrics
+		if err := f.Message(rm.unmarshal); err != nil {
+			return fmt.Errorf("failed to unmarshal resource metrics: %w", err)
+		}
+		rms = append(rms, rm)
+		return nil
+	})
+	return rms, err
+}
+
+func (m *resourceMetrics) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			return f.Message(func(b []byte) error {
+				return wire.UnmarshalFields(b, func(f wire.Field) error {
+					if f.Num != 1 {
+						return nil
+					}
+					return appendAttribute(&m.attributes, f)
+				})
+			})
+		case 2:
+			var sm scopeMetrics
+			if err := f.Message(sm.unmarshal); err != nil {
+				return fmt.Errorf("failed to unmarshal scope metrics: %w", err)
+			}
+			m.scopes = append(m.scopes, sm)
+		}
+		return nil
+	})
+}
+
+func (m *scopeMetrics) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			return f.Message(func(b []byte) error {
+				return wire.UnmarshalFields(b, func(f wire.Field) error {
+					var name string
+					switch f.Num {
+					case 1:
+						name = ScopeNameLabel
+					case 2:
+						name = ScopeVersionLabel
+					case 3:
+						return appendAttribute(&m.attributes, f)
+					default:
+						return nil
+					}
+					var value string
+					if err := f.String(&value); err != nil {
+						return err
+					}
+					if value != "" {
+						m.attributes = append(m.attributes, remote.Label{Name: name, Value: value})
+					}
+					return nil
+				})
+			})
+		case 2:
+			var mt metric
+			if err := f.Message(mt.unmarshal); err != nil {
+				return fmt.Errorf("failed to unmarshal metric: %w", err)
+			}
+			m.metrics = append(m.metrics, mt)
+		}
+		return nil
+	})
+}
+
+func (m *metric) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		if f.Num == 1 {
+			return f.String(&m.name)
+		}
+		kind, ok := metricKinds[uint64(f.Num)]
+		if !ok {
+			return nil
+		}
+		m.kind = kind
+		// Every kind of data has its data points as the field 1.
+		return f.Message(func(b []byte) error {
+			return wire.UnmarshalFields(b, func(f wire.Field) error {
+				if f.Num != 1 {
+					return nil
+				}
+				var p dataPoint
+				if kind == "gauge" || kind == "sum" {
+					if err := f.Message(p.unmarshal); err != nil {
+						return fmt.Errorf("failed to unmarshal data point: %w", err)
+					}
+				}
+				m.points = append(m.points, p)
+				return nil
+			})
+		})
+	})
+}
+
+func (p *dataPoint) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 3:
+			return f.Fixed64(&p.timeUnixNano)
+		case 4, 6:
+			var u uint64
+			if err := f.Fixed64(&u); err != nil {
+				return err
+			}
+			if f.Num == 4 {
+				p.value = math.Float64frombits(u)
+			} else {
+				p.value = float64(int64(u))
+			}
+			p.hasValue = true
+		case 7:
+			return appendAttribute(&p.attributes, f)
+		}
+		return nil
+	})
+}
+
+// appendAttribute appends the KeyValue f as a label to labels.
+func appendAttribute(labels *[]remote.Label, f wire.Field) error {
+	var key string
+	var value interface{}
+	if err := f.Message(func(b []byte) (err error) {
+		key, value, err = unmarshalKeyValue(b)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to unmarshal attribute: %w", err)
+	}
+	*labels = append(*labels, remote.Label{Name: key, Value: attributeString(value)})
+	return nil
+}
+
+func unmarshalKeyValue(b []byte) (key string, value interface{}, err error) {
+	err = wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			return f.String(&key)
+		case 2:
+			return f.Message(func(b []byte) (err error) {
+				value, err = unmarshalAnyValue(b)
+				return err
+			})
+		}
+		return nil
+	})
+	return key, value, err
+}
+
+// unmarshalAnyValue returns an AnyValue as a string, bool, int64, float64,
+// []byte, []interface{} or map[string]interface{}.
+func unmarshalAnyValue(b []byte) (interface{}, error) {
+	var v interface{}
+	err := wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			var s string
+			if err := f.String(&s); err != nil {
+				return err
+			}
+			v = s
+		case 2, 3:
+			var i int64
+			if err := f.Int64(&i); err != nil {
+				return err
+			}
+			if f.Num == 2 {
+				v = i != 0
+			} else {
+				v = i
+			}
+		case 4:
+			var u uint64
+			if err := f.Fixed64(&u); err != nil {
+				return err
+			}
+			v = math.Float64frombits(u)
+		case 5:
+			values := []interface{}{}
+			err := f.Message(func(b []byte) error {
+				return wire.UnmarshalFields(b, func(f wire.Field) error {
+					if f.Num != 1 {
+						return nil
+					}
+					return f.Message(func(b []byte) error {
+						e, err := unmarshalAnyValue(b)
+						values = append(values, e)
+						return err
+					})
+				})
+			})
+			if err != nil {
+				return err
+			}
+			v = values
+		case 6:
+			kvs := map[string]interface{}{}
+			err := f.Message(func(b []byte) error {
+				return wire.UnmarshalFields(b, func(f wire.Field) error {
+					if f.Num != 1 {
+						return nil
+					}
+					return f.Message(func(b []byte) error {
+						key, value, err := unmarshalKeyValue(b)
+						kvs[key] = value
+						return err
+					})
+				})
+			})
+			if err != nil {
+				return err
+			}
+			v = kvs
+		case 7:
+			return f.Message(func(b []byte) error {
+				v = append([]byte{}, b...)
+				return nil
+			})
+		}
+		return nil
+	})
+	return v, err
+}
+
+// attributeString formats an attribute value as a label value.
+// Arrays and maps are formatted in JSON, and bytes in base64.
+func attributeString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// mergeLabels merges label sets, where a label overrides the ones of the
+// same name in the sets before it.
+func mergeLabels(sets ...[]remote.Label) []remote.Label {
+	m := make(map[string]string)
+	for _, set := range sets {
+		for _, l := range set {
+			m[l.Name] = l.Value
+		}
+	}
+	labels := make([]remote.Label, 0, len(m))
+	for name, value := range m {
+		labels = append(labels, remote.Label{Name: name, Value: value})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
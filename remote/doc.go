@@ -0,0 +1,3 @@
+// Package remote provides Prometheus remote write and remote read handlers
+// backed by gorilla blocks.
+package remote
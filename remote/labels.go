@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MetricName is the name of the label holding the metric name.
+const MetricName = "__name__"
+
+// Label is a name-value pair identifying a series.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// SeriesName returns the series name of the label set in the Prometheus
+// exposition format, e.g. `up{instance="a",job="b"}`, with labels sorted by name.
+func SeriesName(labels []Label) string {
+	sorted := make([]Label, 0, len(labels))
+	var name string
+	for _, l := range labels {
+		if l.Name == MetricName {
+			name = l.Value
+			continue
+		}
+		sorted = append(sorted, l)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	if len(sorted) == 0 && name != "" {
+		return sb.String()
+	}
+	sb.WriteByte('{')
+	for i, l := range sorted {
+		if 0 < i {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(l.Name)
+		sb.WriteString(`="`)
+		sb.WriteString(escaper.Replace(l.Value))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ParseSeriesName returns the label set of a series name returned by SeriesName.
+func ParseSeriesName(s string) ([]Label, error) {
+	var labels []Label
+	i := strings.IndexByte(s, '{')
+	if i < 0 {
+		if s == "" {
+			return nil, errors.New("empty series name")
+		}
+		return []Label{{MetricName, s}}, nil
+	}
+	if 0 < i {
+		labels = append(labels, Label{MetricName, s[:i]})
+	}
+	rest := s[i+1:]
+	for first := true; ; first = false {
+		if rest == "}" {
+			return labels, nil
+		}
+		if !first {
+			if !strings.HasPrefix(rest, ",") {
+				return nil, fmt.Errorf("expected ',' in series name %q", s)
+			}
+			rest = rest[1:]
+		}
+		eq := strings.Index(rest, `="`)
+		if eq <= 0 {
+			return nil, fmt.Errorf("expected label name in series name %q", s)
+		}
+		name := rest[:eq]
+		rest = rest[eq+2:]
+		var value strings.Builder
+		for {
+			if rest == "" {
+				return nil, fmt.Errorf("unterminated label value in series name %q", s)
+			}
+			c := rest[0]
+			rest = rest[1:]
+			if c == '"' {
+				break
+			}
+			if c == '\\' && rest != "" {
+				switch rest[0] {
+				case 'n':
+					c = '\n'
+				default:
+					c = rest[0]
+				}
+				rest = rest[1:]
+			}
+			value.WriteByte(c)
+		}
+		labels = append(labels, Label{name, value.String()})
+	}
+}
@@ -0,0 +1,54 @@
+package remote_test
+
+import (
+	"testing"
+
+	"github.com/keisku/gorilla/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SeriesName(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []remote.Label
+		want   string
+	}{
+		{
+			name:   "name only",
+			labels: []remote.Label{{remote.MetricName, "up"}},
+			want:   "up",
+		},
+		{
+			name:   "sorted labels",
+			labels: []remote.Label{{"job", "node"}, {remote.MetricName, "up"}, {"instance", "a:9100"}},
+			want:   `up{instance="a:9100",job="node"}`,
+		},
+		{
+			name:   "escaped value",
+			labels: []remote.Label{{remote.MetricName, "up"}, {"path", "C:\\\"x\"\n"}},
+			want:   `up{path="C:\\\"x\"\n"}`,
+		},
+		{
+			name:   "no name",
+			labels: []remote.Label{{"job", "node"}},
+			want:   `{job="node"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := remote.SeriesName(tt.labels)
+			assert.Equal(t, tt.want, got)
+			labels, err := remote.ParseSeriesName(got)
+			require.Nil(t, err)
+			assert.Equal(t, got, remote.SeriesName(labels))
+		})
+	}
+}
+
+func Test_ParseSeriesName_Invalid(t *testing.T) {
+	for _, s := range []string{"", `up{job="a"`, `up{job}`, `up{a="b"c="d"}`} {
+		_, err := remote.ParseSeriesName(s)
+		assert.NotNil(t, err, s)
+	}
+}
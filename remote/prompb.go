@@ -0,0 +1,397 @@
+package remote
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/keisku/gorilla/internal/wire"
+)
+
+// The messages below mirror the ones of Prometheus' prompb package with the
+// fields this package uses. Unknown fields are skipped when unmarshaling.
+
+// WriteRequest is the body of a remote write request.
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+// TimeSeries is a series and its samples.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// Sample is a point with a millisecond timestamp.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *WriteRequest) Marshal() []byte {
+	var b []byte
+	for i := range m.Timeseries {
+		b = wire.AppendMessage(b, 1, m.Timeseries[i].marshal(nil))
+	}
+	return b
+}
+
+// Unmarshal parses the protobuf encoding of a WriteRequest into m.
+func (m *WriteRequest) Unmarshal(b []byte) error {
+	*m = WriteRequest{}
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		var ts TimeSeries
+		if err := f.Message(ts.unmarshal); err != nil {
+			return fmt.Errorf("failed to unmarshal timeseries: %w", err)
+		}
+		m.Timeseries = append(m.Timeseries, ts)
+		return nil
+	})
+}
+
+func (m *TimeSeries) marshal(b []byte) []byte {
+	for _, l := range m.Labels {
+		b = wire.AppendMessage(b, 1, marshalLabel(nil, l))
+	}
+	for _, s := range m.Samples {
+		var sb []byte
+		sb = wire.AppendFixed64(sb, 1, math.Float64bits(s.Value))
+		sb = wire.AppendVarint(sb, 2, uint64(s.Timestamp))
+		b = wire.AppendMessage(b, 2, sb)
+	}
+	return b
+}
+
+func (m *TimeSeries) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			var l Label
+			if err := f.Message(func(b []byte) error { return unmarshalLabel(b, &l) }); err != nil {
+				return fmt.Errorf("failed to unmarshal label: %w", err)
+			}
+			m.Labels = append(m.Labels, l)
+		case 2:
+			var s Sample
+			if err := f.Message(func(b []byte) error { return unmarshalSample(b, &s) }); err != nil {
+				return fmt.Errorf("failed to unmarshal sample: %w", err)
+			}
+			m.Samples = append(m.Samples, s)
+		}
+		return nil
+	})
+}
+
+func marshalLabel(b []byte, l Label) []byte {
+	b = wire.AppendString(b, 1, l.Name)
+	return wire.AppendString(b, 2, l.Value)
+}
+
+func unmarshalLabel(b []byte, l *Label) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			return f.String(&l.Name)
+		case 2:
+			return f.String(&l.Value)
+		}
+		return nil
+	})
+}
+
+func unmarshalSample(b []byte, s *Sample) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			var u uint64
+			if err := f.Fixed64(&u); err != nil {
+				return err
+			}
+			s.Value = math.Float64frombits(u)
+		case 2:
+			return f.Int64(&s.Timestamp)
+		}
+		return nil
+	})
+}
+
+// ResponseType is a response type a remote read client accepts.
+type ResponseType int32
+
+const (
+	// Samples responds a snappy-compressed ReadResponse.
+	Samples ResponseType = 0
+	// StreamedXORChunks streams ChunkedReadResponses of XOR chunks.
+	StreamedXORChunks ResponseType = 1
+)
+
+// ReadRequest is the body of a remote read request.
+type ReadRequest struct {
+	Queries               []Query
+	AcceptedResponseTypes []ResponseType
+}
+
+// Query selects series by label matchers and samples by a millisecond time range.
+type Query struct {
+	StartTimestampMs int64
+	EndTimestampMs   int64
+	Matchers         []LabelMatcher
+}
+
+// MatchType is a type of LabelMatcher.
+type MatchType int32
+
+const (
+	MatchEqual     MatchType = 0
+	MatchNotEqual  MatchType = 1
+	MatchRegexp    MatchType = 2
+	MatchNotRegexp MatchType = 3
+)
+
+// LabelMatcher matches the value of a label.
+type LabelMatcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+}
+
+// ReadResponse is the body of a remote read response with samples.
+type ReadResponse struct {
+	Results []QueryResult
+}
+
+// QueryResult has the series matched by a query.
+type QueryResult struct {
+	Timeseries []TimeSeries
+}
+
+// ChunkedReadResponse is a frame of a streamed remote read response.
+type ChunkedReadResponse struct {
+	ChunkedSeries []ChunkedSeries
+	QueryIndex    int64
+}
+
+// ChunkedSeries is a series and its chunks.
+type ChunkedSeries struct {
+	Labels []Label
+	Chunks []Chunk
+}
+
+// ChunkEncoding is the encoding of a Chunk.
+type ChunkEncoding int32
+
+// XOR is the encoding of Prometheus float chunks.
+const XOR ChunkEncoding = 1
+
+// Chunk is a chunk of samples within a millisecond time range.
+type Chunk struct {
+	MinTimeMs int64
+	MaxTimeMs int64
+	Type      ChunkEncoding
+	Data      []byte
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *ReadRequest) Marshal() []byte {
+	var b []byte
+	for _, q := range m.Queries {
+		var qb []byte
+		qb = wire.AppendVarint(qb, 1, uint64(q.StartTimestampMs))
+		qb = wire.AppendVarint(qb, 2, uint64(q.EndTimestampMs))
+		for _, lm := range q.Matchers {
+			var mb []byte
+			mb = wire.AppendVarint(mb, 1, uint64(lm.Type))
+			mb = wire.AppendString(mb, 2, lm.Name)
+			mb = wire.AppendString(mb, 3, lm.Value)
+			qb = wire.AppendMessage(qb, 3, mb)
+		}
+		b = wire.AppendMessage(b, 1, qb)
+	}
+	for _, t := range m.AcceptedResponseTypes {
+		b = wire.AppendVarint(b, 2, uint64(t))
+	}
+	return b
+}
+
+// Unmarshal parses the protobuf encoding of a ReadRequest into m.
+func (m *ReadRequest) Unmarshal(b []byte) error {
+	*m = ReadRequest{}
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			var q Query
+			if err := f.Message(q.unmarshal); err != nil {
+				return fmt.Errorf("failed to unmarshal query: %w", err)
+			}
+			m.Queries = append(m.Queries, q)
+		case 2:
+			return f.PackedVarints(func(u uint64) {
+				m.AcceptedResponseTypes = append(m.AcceptedResponseTypes, ResponseType(u))
+			})
+		}
+		return nil
+	})
+}
+
+func (m *Query) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			return f.Int64(&m.StartTimestampMs)
+		case 2:
+			return f.Int64(&m.EndTimestampMs)
+		case 3:
+			var lm LabelMatcher
+			if err := f.Message(lm.unmarshal); err != nil {
+				return fmt.Errorf("failed to unmarshal matcher: %w", err)
+			}
+			m.Matchers = append(m.Matchers, lm)
+		}
+		return nil
+	})
+}
+
+func (m *LabelMatcher) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			var t int64
+			if err := f.Int64(&t); err != nil {
+				return err
+			}
+			m.Type = MatchType(t)
+		case 2:
+			return f.String(&m.Name)
+		case 3:
+			return f.String(&m.Value)
+		}
+		return nil
+	})
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *ReadResponse) Marshal() []byte {
+	var b []byte
+	for _, r := range m.Results {
+		var rb []byte
+		for i := range r.Timeseries {
+			rb = wire.AppendMessage(rb, 1, r.Timeseries[i].marshal(nil))
+		}
+		b = wire.AppendMessage(b, 1, rb)
+	}
+	return b
+}
+
+// Unmarshal parses the protobuf encoding of a ReadResponse into m.
+func (m *ReadResponse) Unmarshal(b []byte) error {
+	*m = ReadResponse{}
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		var r QueryResult
+		err := f.Message(func(b []byte) error {
+			return wire.UnmarshalFields(b, func(f wire.Field) error {
+				if f.Num != 1 {
+					return nil
+				}
+				var ts TimeSeries
+				if err := f.Message(ts.unmarshal); err != nil {
+					return err
+				}
+				r.Timeseries = append(r.Timeseries, ts)
+				return nil
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal result: %w", err)
+		}
+		m.Results = append(m.Results, r)
+		return nil
+	})
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *ChunkedReadResponse) Marshal() []byte {
+	var b []byte
+	for _, s := range m.ChunkedSeries {
+		var sb []byte
+		for _, l := range s.Labels {
+			sb = wire.AppendMessage(sb, 1, marshalLabel(nil, l))
+		}
+		for _, c := range s.Chunks {
+			var cb []byte
+			cb = wire.AppendVarint(cb, 1, uint64(c.MinTimeMs))
+			cb = wire.AppendVarint(cb, 2, uint64(c.MaxTimeMs))
+			cb = wire.AppendVarint(cb, 3, uint64(c.Type))
+			cb = wire.AppendBytes(cb, 4, c.Data)
+			sb = wire.AppendMessage(sb, 2, cb)
+		}
+		b = wire.AppendMessage(b, 1, sb)
+	}
+	return wire.AppendVarint(b, 2, uint64(m.QueryIndex))
+}
+
+// Unmarshal parses the protobuf encoding of a ChunkedReadResponse into m.
+func (m *ChunkedReadResponse) Unmarshal(b []byte) error {
+	*m = ChunkedReadResponse{}
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			var s ChunkedSeries
+			if err := f.Message(s.unmarshal); err != nil {
+				return fmt.Errorf("failed to unmarshal chunked series: %w", err)
+			}
+			m.ChunkedSeries = append(m.ChunkedSeries, s)
+		case 2:
+			return f.Int64(&m.QueryIndex)
+		}
+		return nil
+	})
+}
+
+func (m *ChunkedSeries) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			var l Label
+			if err := f.Message(func(b []byte) error { return unmarshalLabel(b, &l) }); err != nil {
+				return fmt.Errorf("failed to unmarshal label: %w", err)
+			}
+			m.Labels = append(m.Labels, l)
+		case 2:
+			var c Chunk
+			if err := f.Message(c.unmarshal); err != nil {
+				return fmt.Errorf("failed to unmarshal chunk: %w", err)
+			}
+			m.Chunks = append(m.Chunks, c)
+		}
+		return nil
+	})
+}
+
+func (m *Chunk) unmarshal(b []byte) error {
+	return wire.UnmarshalFields(b, func(f wire.Field) error {
+		switch f.Num {
+		case 1:
+			return f.Int64(&m.MinTimeMs)
+		case 2:
+			return f.Int64(&m.MaxTimeMs)
+		case 3:
+			var t int64
+			if err := f.Int64(&t); err != nil {
+				return err
+			}
+			m.Type = ChunkEncoding(t)
+		case 4:
+			return f.Message(func(b []byte) error {
+				m.Data = append([]byte{}, b...)
+				return nil
+			})
+		}
+		return nil
+	})
+}
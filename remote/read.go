@@ -0,0 +1,253 @@
+package remote
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/golang/snappy"
+	"github.com/keisku/gorilla/internal/xorchunk"
+	"github.com/keisku/gorilla/tsdb"
+)
+
+// Queryable is a source of series, e.g. a *tsdb.Store or a *tsdb.BlockStore.
+type Queryable interface {
+	Series() []string
+	Query(series string, from, to uint32) *tsdb.Iterator
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ReadHandler answers Prometheus remote read requests by decompressing the
+// gorilla blocks of the series selected by each query. It responds samples,
+// or streams XOR chunks when the client accepts them first.
+// Series whose names are not in the format of SeriesName are never selected.
+type ReadHandler struct {
+	q Queryable
+}
+
+// NewReadHandler returns a ReadHandler reading series from q.
+func NewReadHandler(q Queryable) *ReadHandler {
+	return &ReadHandler{q: q}
+}
+
+func (h *ReadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ReadRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	matchers := make([][]matcher, len(req.Queries))
+	for i, q := range req.Queries {
+		ms, err := newMatchers(q.Matchers)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		matchers[i] = ms
+	}
+
+	responseType := Samples
+	if 0 < len(req.AcceptedResponseTypes) {
+		responseType = req.AcceptedResponseTypes[0]
+	}
+	switch responseType {
+	case Samples:
+		h.serveSamples(w, req.Queries, matchers)
+	case StreamedXORChunks:
+		h.serveChunks(w, req.Queries, matchers)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported response type: %d", responseType), http.StatusBadRequest)
+	}
+}
+
+func (h *ReadHandler) serveSamples(w http.ResponseWriter, queries []Query, matchers [][]matcher) {
+	resp := ReadResponse{Results: make([]QueryResult, len(queries))}
+	for i, q := range queries {
+		err := h.selectSeries(q, matchers[i], func(labels []Label, samples []Sample) error {
+			resp.Results[i].Timeseries = append(resp.Results[i].Timeseries, TimeSeries{labels, samples})
+			return nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	w.Write(snappy.Encode(nil, resp.Marshal()))
+}
+
+// serveChunks writes a frame per series, consisting of the size of the
+// ChunkedReadResponse (uvarint), its CRC32-C (4 bytes) and itself.
+func (h *ReadHandler) serveChunks(w http.ResponseWriter, queries []Query, matchers [][]matcher) {
+	w.Header().Set("Content-Type", "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse")
+	flusher, _ := w.(http.Flusher)
+	written := false
+	for i, q := range queries {
+		err := h.selectSeries(q, matchers[i], func(labels []Label, samples []Sample) error {
+			resp := ChunkedReadResponse{
+				ChunkedSeries: []ChunkedSeries{{Labels: labels, Chunks: encodeChunks(samples)}},
+				QueryIndex:    int64(i),
+			}
+			b := resp.Marshal()
+			var header [binary.MaxVarintLen64 + 4]byte
+			n := binary.PutUvarint(header[:], uint64(len(b)))
+			binary.BigEndian.PutUint32(header[n:], crc32.Checksum(b, castagnoli))
+			if _, err := w.Write(header[:n+4]); err != nil {
+				return err
+			}
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+			written = true
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			// Once the first frame is written with the status, the client
+			// detects the failure by the truncated stream.
+			if !written {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+}
+
+// maxSamplesPerChunk is the number of samples Prometheus cuts XOR chunks at.
+const maxSamplesPerChunk = 120
+
+func encodeChunks(samples []Sample) []Chunk {
+	var chunks []Chunk
+	for 0 < len(samples) {
+		n := len(samples)
+		if maxSamplesPerChunk < n {
+			n = maxSamplesPerChunk
+		}
+		c := xorchunk.NewEncoder()
+		for _, s := range samples[:n] {
+			c.Append(s.Timestamp, s.Value)
+		}
+		chunks = append(chunks, Chunk{
+			MinTimeMs: samples[0].Timestamp,
+			MaxTimeMs: samples[n-1].Timestamp,
+			Type:      XOR,
+			Data:      c.Bytes(),
+		})
+		samples = samples[n:]
+	}
+	return chunks
+}
+
+// selectSeries calls fn with the sorted labels and the samples in range of
+// each series matched by the query and having samples in range.
+func (h *ReadHandler) selectSeries(q Query, matchers []matcher, fn func([]Label, []Sample) error) error {
+	from, to, ok := secondsRange(q.StartTimestampMs, q.EndTimestampMs)
+	if !ok {
+		return nil
+	}
+	for _, name := range h.q.Series() {
+		labels, err := ParseSeriesName(name)
+		if err != nil || !matchLabels(matchers, labels) {
+			continue
+		}
+		var samples []Sample
+		it := h.q.Query(name, from, to)
+		for it.Next() {
+			t, v := it.At()
+			samples = append(samples, Sample{Value: v, Timestamp: int64(t) * 1000})
+		}
+		if err := it.Err(); err != nil {
+			return fmt.Errorf("failed to query %s: %w", name, err)
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		if err := fn(labels, samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secondsRange converts a millisecond time range into the seconds within it.
+func secondsRange(start, end int64) (from, to uint32, ok bool) {
+	if end < 0 || end < start {
+		return 0, 0, false
+	}
+	if 0 < start {
+		s := (start + 999) / 1000
+		if math.MaxUint32 < s {
+			return 0, 0, false
+		}
+		from = uint32(s)
+	}
+	to = math.MaxUint32
+	if end/1000 < math.MaxUint32 {
+		to = uint32(end / 1000)
+	}
+	return from, to, from <= to
+}
+
+type matcher struct {
+	name  string
+	match func(string) bool
+}
+
+func newMatchers(lms []LabelMatcher) ([]matcher, error) {
+	matchers := make([]matcher, 0, len(lms))
+	for _, lm := range lms {
+		value := lm.Value
+		m := matcher{name: lm.Name}
+		switch lm.Type {
+		case MatchEqual:
+			m.match = func(s string) bool { return s == value }
+		case MatchNotEqual:
+			m.match = func(s string) bool { return s != value }
+		case MatchRegexp, MatchNotRegexp:
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regexp of matcher %s: %w", lm.Name, err)
+			}
+			m.match = re.MatchString
+			if lm.Type == MatchNotRegexp {
+				m.match = func(s string) bool { return !re.MatchString(s) }
+			}
+		default:
+			return nil, fmt.Errorf("unknown type of matcher %s: %d", lm.Name, lm.Type)
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// matchLabels reports whether the labels satisfy all matchers.
+// A missing label matches as an empty value.
+func matchLabels(matchers []matcher, labels []Label) bool {
+	for _, m := range matchers {
+		var value string
+		for _, l := range labels {
+			if l.Name == m.name {
+				value = l.Value
+				break
+			}
+		}
+		if !m.match(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package remote_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/keisku/gorilla/remote"
+	"github.com/keisku/gorilla/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func read(t *testing.T, url string, req *remote.ReadRequest) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url, "application/x-protobuf", bytes.NewReader(snappy.Encode(nil, req.Marshal())))
+	require.Nil(t, err)
+	return resp
+}
+
+func newReadServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	for i := 0; i < 300; i++ {
+		ts := uint32(1600000000 + i*15)
+		require.Nil(t, s.Append(`up{instance="a",job="node"}`, ts, 1))
+		require.Nil(t, s.Append(`up{instance="b",job="node"}`, ts, 0))
+		require.Nil(t, s.Append(`load{instance="a"}`, ts, float64(i)))
+	}
+	srv := httptest.NewServer(remote.NewReadHandler(s))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func Test_ReadHandler_Samples(t *testing.T) {
+	srv := newReadServer(t)
+
+	resp := read(t, srv.URL, &remote.ReadRequest{Queries: []remote.Query{
+		{
+			StartTimestampMs: 1600000000001,
+			EndTimestampMs:   1600000030000,
+			Matchers: []remote.LabelMatcher{
+				{Type: remote.MatchEqual, Name: remote.MetricName, Value: "up"},
+				{Type: remote.MatchNotEqual, Name: "instance", Value: "b"},
+			},
+		},
+		{
+			StartTimestampMs: 0,
+			EndTimestampMs:   1700000000000,
+			Matchers: []remote.LabelMatcher{
+				{Type: remote.MatchRegexp, Name: "instance", Value: "a|b"},
+				{Type: remote.MatchNotRegexp, Name: remote.MetricName, Value: "u.*"},
+			},
+		},
+	}})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "snappy", resp.Header.Get("Content-Encoding"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.Nil(t, err)
+	b, err := snappy.Decode(nil, body)
+	require.Nil(t, err)
+	var rr remote.ReadResponse
+	require.Nil(t, rr.Unmarshal(b))
+	require.Len(t, rr.Results, 2)
+
+	require.Len(t, rr.Results[0].Timeseries, 1)
+	assert.Equal(t, []remote.Label{{remote.MetricName, "up"}, {"instance", "a"}, {"job", "node"}}, rr.Results[0].Timeseries[0].Labels)
+	assert.Equal(t, []remote.Sample{{1, 1600000015000}, {1, 1600000030000}}, rr.Results[0].Timeseries[0].Samples)
+
+	require.Len(t, rr.Results[1].Timeseries, 1)
+	assert.Equal(t, []remote.Label{{remote.MetricName, "load"}, {"instance", "a"}}, rr.Results[1].Timeseries[0].Labels)
+	assert.Len(t, rr.Results[1].Timeseries[0].Samples, 300)
+}
+
+func Test_ReadHandler_StreamedXORChunks(t *testing.T) {
+	srv := newReadServer(t)
+
+	resp := read(t, srv.URL, &remote.ReadRequest{
+		Queries: []remote.Query{{
+			StartTimestampMs: 0,
+			EndTimestampMs:   1700000000000,
+			Matchers:         []remote.LabelMatcher{{Type: remote.MatchEqual, Name: "job", Value: "node"}},
+		}},
+		AcceptedResponseTypes: []remote.ResponseType{remote.StreamedXORChunks, remote.Samples},
+	})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	r := bufio.NewReader(resp.Body)
+	var frames []remote.ChunkedReadResponse
+	for {
+		size, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		var sum uint32
+		require.Nil(t, binary.Read(r, binary.BigEndian, &sum))
+		b := make([]byte, size)
+		_, err = io.ReadFull(r, b)
+		require.Nil(t, err)
+		assert.Equal(t, crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)), sum)
+
+		var frame remote.ChunkedReadResponse
+		require.Nil(t, frame.Unmarshal(b))
+		frames = append(frames, frame)
+	}
+
+	require.Len(t, frames, 2)
+	for i, instance := range []string{"a", "b"} {
+		require.Len(t, frames[i].ChunkedSeries, 1)
+		cs := frames[i].ChunkedSeries[0]
+		assert.Equal(t, []remote.Label{{remote.MetricName, "up"}, {"instance", instance}, {"job", "node"}}, cs.Labels)
+		require.Len(t, cs.Chunks, 3)
+		assert.Equal(t, int64(1600000000000), cs.Chunks[0].MinTimeMs)
+		assert.Equal(t, int64(1600000000000+119*15000), cs.Chunks[0].MaxTimeMs)
+		assert.Equal(t, int64(1600000000000+299*15000), cs.Chunks[2].MaxTimeMs)
+		for _, c := range cs.Chunks {
+			assert.Equal(t, remote.XOR, c.Type)
+		}
+		assert.Equal(t, uint16(60), binary.BigEndian.Uint16(cs.Chunks[2].Data))
+	}
+}
+
+func Test_ReadHandler_BadRequest(t *testing.T) {
+	srv := newReadServer(t)
+	resp := read(t, srv.URL, &remote.ReadRequest{Queries: []remote.Query{{
+		EndTimestampMs: 1700000000000,
+		Matchers:       []remote.LabelMatcher{{Type: remote.MatchRegexp, Name: "job", Value: "("}},
+	}}})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func Test_ReadHandler_StreamedXORChunks_Error(t *testing.T) {
+	bs, err := tsdb.OpenBlockStore(t.TempDir())
+	require.Nil(t, err)
+	defer bs.Close()
+	// The block is too short to have a header.
+	require.Nil(t, bs.Write(map[string][]*tsdb.Block{
+		"up": {{MinTime: 1600000000, MaxTime: 1600000000, Count: 1, Data: []byte{1}}},
+	}))
+	srv := httptest.NewServer(remote.NewReadHandler(bs))
+	defer srv.Close()
+
+	resp := read(t, srv.URL, &remote.ReadRequest{
+		Queries: []remote.Query{{
+			EndTimestampMs: 1700000000000,
+			Matchers:       []remote.LabelMatcher{{Type: remote.MatchEqual, Name: remote.MetricName, Value: "up"}},
+		}},
+		AcceptedResponseTypes: []remote.ResponseType{remote.StreamedXORChunks},
+	})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func Test_ReadRequest_Unmarshal_PackedResponseTypes(t *testing.T) {
+	var req remote.ReadRequest
+	require.Nil(t, req.Unmarshal([]byte{0x12, 0x02, 0x01, 0x00}))
+	assert.Equal(t, []remote.ResponseType{remote.StreamedXORChunks, remote.Samples}, req.AcceptedResponseTypes)
+}
@@ -0,0 +1,127 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/golang/snappy"
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/tsdb"
+)
+
+// WriteHandler receives Prometheus remote write requests and appends their
+// samples to per-series compressors. Millisecond timestamps are truncated
+// to the seconds gorilla blocks store, so samples of a series within the
+// same second are all appended with that second. The number of samples
+// appended with the same second as the previous sample of their time series
+// in the request is reported in the CollidingSamplesHeader of the response.
+//
+// It responds 204 when every sample is appended, 400 when the request is
+// malformed, its body is larger than 32 MiB before or after it is
+// decompressed, or some samples are out of order or out of range, and 500
+// when the appender fails otherwise.
+type WriteHandler struct {
+	appender tsdb.Appender
+}
+
+// CollidingSamplesHeader is the response header of WriteHandler with the
+// number of samples whose timestamp was truncated to the same second as the
+// previous sample of their time series. It is omitted when there are none.
+const CollidingSamplesHeader = "X-Colliding-Samples"
+
+// NewWriteHandler returns a WriteHandler appending samples to a.
+func NewWriteHandler(a tsdb.Appender) *WriteHandler {
+	return &WriteHandler{appender: a}
+}
+
+func (h *WriteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req WriteRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rejected, colliding int
+	var firstErr error
+	for _, ts := range req.Timeseries {
+		series := SeriesName(ts.Labels)
+		var last uint32
+		for _, s := range ts.Samples {
+			t, err := h.append(series, s)
+			if err == nil {
+				if t == last {
+					colliding++
+				}
+				last = t
+				continue
+			}
+			if !errors.Is(err, tsdb.ErrOutOfOrder) && !errors.Is(err, tsdb.ErrOutOfRange) {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if rejected == 0 {
+				firstErr = err
+			}
+			rejected++
+		}
+	}
+	if 0 < colliding {
+		w.Header().Set(CollidingSamplesHeader, strconv.Itoa(colliding))
+	}
+	if 0 < rejected {
+		http.Error(w, fmt.Sprintf("rejected %d samples: %v", rejected, firstErr), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// append appends s to the series, returning its timestamp in seconds.
+func (h *WriteHandler) append(series string, s Sample) (uint32, error) {
+	t, err := gorilla.UnixSeconds(s.Timestamp / 1000)
+	if err != nil {
+		return 0, fmt.Errorf("failed to append to %s: %w", series, err)
+	}
+	if err := h.appender.Append(series, t, s.Value); err != nil {
+		return 0, fmt.Errorf("failed to append to %s: %w", series, err)
+	}
+	return t, nil
+}
+
+// maxBodySize is the largest size in bytes of a request body, both before
+// and after it is decompressed.
+const maxBodySize = 32 << 20
+
+// decodeRequest reads the snappy-compressed protobuf body of r into m.
+func decodeRequest(w http.ResponseWriter, r *http.Request, m interface{ Unmarshal([]byte) error }) error {
+	if enc := r.Header.Get("Content-Encoding"); enc != "" && enc != "snappy" {
+		return fmt.Errorf("unsupported content encoding: %s", enc)
+	}
+	compressed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	// Check the length in the snappy header before Decode allocates it.
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return fmt.Errorf("failed to decompress body: %w", err)
+	}
+	if maxBodySize < n {
+		return fmt.Errorf("decompressed body is larger than %d bytes: %d", maxBodySize, n)
+	}
+	b, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return fmt.Errorf("failed to decompress body: %w", err)
+	}
+	if err := m.Unmarshal(b); err != nil {
+		return fmt.Errorf("failed to unmarshal body: %w", err)
+	}
+	return nil
+}
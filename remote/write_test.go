@@ -0,0 +1,113 @@
+package remote_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/keisku/gorilla/remote"
+	"github.com/keisku/gorilla/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type point struct {
+	t uint32
+	v float64
+}
+
+func query(t *testing.T, s *tsdb.Store, series string) []point {
+	t.Helper()
+	var points []point
+	it := s.Query(series, 0, 1<<32-1)
+	for it.Next() {
+		ts, v := it.At()
+		points = append(points, point{ts, v})
+	}
+	require.Nil(t, it.Err())
+	return points
+}
+
+func post(t *testing.T, url string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(snappy.Encode(nil, body)))
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func Test_WriteHandler(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	srv := httptest.NewServer(remote.NewWriteHandler(s))
+	defer srv.Close()
+
+	req := &remote.WriteRequest{Timeseries: []remote.TimeSeries{
+		{
+			Labels:  []remote.Label{{remote.MetricName, "up"}, {"job", "node"}},
+			Samples: []remote.Sample{{1, 1600000000000}, {0, 1600000015500}},
+		},
+		{
+			Labels:  []remote.Label{{remote.MetricName, "load"}},
+			Samples: []remote.Sample{{0.5, 1600000000999}},
+		},
+	}}
+	resp := post(t, srv.URL, req.Marshal())
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, []point{{1600000000, 1}, {1600000015, 0}}, query(t, s, `up{job="node"}`))
+	assert.Equal(t, []point{{1600000000, 0.5}}, query(t, s, "load"))
+	assert.Empty(t, resp.Header.Get(remote.CollidingSamplesHeader))
+
+	// Samples within the same second are appended with that second and counted.
+	req = &remote.WriteRequest{Timeseries: []remote.TimeSeries{
+		{
+			Labels:  []remote.Label{{remote.MetricName, "up"}, {"job", "node"}},
+			Samples: []remote.Sample{{1, 1600000030000}, {0, 1600000030500}, {1, 1600000031000}},
+		},
+	}}
+	resp = post(t, srv.URL, req.Marshal())
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get(remote.CollidingSamplesHeader))
+	assert.Equal(t, []point{{1600000000, 1}, {1600000015, 0}, {1600000030, 1}, {1600000030, 0}, {1600000031, 1}}, query(t, s, `up{job="node"}`))
+
+	// Out of order and out of range samples are rejected while the others are appended.
+	req = &remote.WriteRequest{Timeseries: []remote.TimeSeries{
+		{
+			Labels:  []remote.Label{{remote.MetricName, "load"}},
+			Samples: []remote.Sample{{0.1, 1599999999000}, {0.7, 1600000030000}, {0.2, -1}},
+		},
+	}}
+	resp = post(t, srv.URL, req.Marshal())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, []point{{1600000000, 0.5}, {1600000030, 0.7}}, query(t, s, "load"))
+
+	resp = post(t, srv.URL, []byte{0x0a, 0xff})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// A snappy header claiming 4 GiB is rejected before it is allocated.
+	resp, err = http.Post(srv.URL, "application/x-protobuf", bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x0f}))
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func Test_WriteRequest_Unmarshal(t *testing.T) {
+	req := &remote.WriteRequest{Timeseries: []remote.TimeSeries{{
+		Labels:  []remote.Label{{remote.MetricName, "up"}},
+		Samples: []remote.Sample{{1.5, 1600000000000}, {-2, 1600000015000}},
+	}}}
+	var got remote.WriteRequest
+	require.Nil(t, got.Unmarshal(req.Marshal()))
+	assert.Equal(t, *req, got)
+}
@@ -0,0 +1,117 @@
+package gorilla
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SalvageReport is the result of Salvage.
+type SalvageReport struct {
+	Header uint32 `json:"header"`
+	Bytes  int    `json:"bytes"`
+	// Points is the number of recovered points.
+	Points int `json:"points"`
+	// Problem describes the damage stopping the decompression, or is empty if
+	// the block is intact up to its finish marker.
+	Problem string `json:"problem,omitempty"`
+	// Offset is the bit offset where the damage starts.
+	Offset uint64 `json:"offset,omitempty"`
+	// LostBits is the number of bits from Offset to the end of the block,
+	// which could not be decoded into points.
+	LostBits uint64 `json:"lostBits,omitempty"`
+}
+
+// Intact reports whether no point was lost.
+func (r SalvageReport) Intact() bool {
+	return r.Problem == ""
+}
+
+func (r SalvageReport) String() string {
+	if r.Intact() {
+		return fmt.Sprintf("intact: %d points", r.Points)
+	}
+	return fmt.Sprintf("recovered %d points, lost %d bits from bit %d: %s", r.Points, r.LostBits, r.Offset, r.Problem)
+}
+
+// Salvage decompresses the block read from r and returns the points decoded
+// before its finish marker or its first damage: the end of a truncated block,
+// an impossible bit header, a timestamp before the previous one, or a value
+// reusing a meaningful bits window before any is stored. The damaged point and
+// everything after it are counted in the LostBits of the report.
+//
+// It returns ErrTruncatedBlock if r ends within the header, or the error reading r.
+func Salvage(r io.Reader) ([]Point, SalvageReport, error) {
+	cr := &countingReader{r: r}
+	d, header, err := NewDecompressor(cr)
+	if err != nil {
+		if cr.err != nil {
+			return nil, SalvageReport{}, cr.err
+		}
+		return nil, SalvageReport{}, fmt.Errorf("%w: %v", ErrTruncatedBlock, err)
+	}
+	report := SalvageReport{Header: header}
+	var points []Point
+	// damaged stops decoding at the first point which cannot be trusted.
+	var damaged error
+	windowed := false
+	offset, err := d.traceAll(func(p *pointTrace) {
+		switch {
+		case damaged != nil:
+			return
+		case 0 < len(points) && p.t < points[len(points)-1].T:
+			damaged = fmt.Errorf("timestamp %d is before %d", p.t, points[len(points)-1].T)
+		case p.valueCase == valueReuse && !windowed:
+			damaged = errors.New("value reuses a meaningful bits window before any is stored")
+		default:
+			windowed = windowed || p.valueCase == valueNew
+			points = append(points, Point{T: p.t, V: p.v})
+			return
+		}
+		report.Offset = p.offset
+	})
+	switch {
+	case damaged != nil:
+		report.Problem = damaged.Error()
+	case errors.Is(err, errEndOfBlock):
+	case errors.Is(err, io.EOF):
+		report.Problem, report.Offset = ErrTruncatedBlock.Error(), offset
+	default:
+		report.Problem, report.Offset = err.Error(), offset
+	}
+	// The rest of r is counted as lost.
+	io.Copy(io.Discard, cr)
+	if cr.err != nil {
+		return nil, SalvageReport{}, cr.err
+	}
+	report.Bytes = cr.n
+	report.Points = len(points)
+	if !report.Intact() {
+		report.LostBits = uint64(cr.n)*8 - report.Offset
+	}
+	return points, report, nil
+}
+
+// Repair salvages the block read from r and compresses the recovered points
+// into a new block with the same header and a finish marker.
+func Repair(r io.Reader) ([]byte, SalvageReport, error) {
+	points, report, err := Salvage(r)
+	if err != nil {
+		return nil, report, err
+	}
+	buf := new(bytes.Buffer)
+	c, finish, err := NewCompressor(buf, report.Header)
+	if err != nil {
+		return nil, report, err
+	}
+	for _, p := range points {
+		if err := c.Compress(p.T, p.V); err != nil {
+			return nil, report, err
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, report, fmt.Errorf("failed to finish block: %w", err)
+	}
+	return buf.Bytes(), report, nil
+}
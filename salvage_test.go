@@ -0,0 +1,97 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Salvage(t *testing.T) {
+	want := []point{{1600000000, 1}, {1600000060, 2}, {1600000120, 2}, {1600000185, 2.5}, {1600000240, -1}}
+	b := compress(t, 1600000000, want)
+
+	points, report, err := gorilla.Salvage(bytes.NewReader(b))
+	require.Nil(t, err)
+	assert.Equal(t, gorilla.SalvageReport{Header: 1600000000, Bytes: len(b), Points: 5}, report)
+	assert.True(t, report.Intact())
+	require.Len(t, points, 5)
+	for i, p := range points {
+		assert.Equal(t, want[i], point{p.T, p.V})
+	}
+
+	repaired, report, err := gorilla.Repair(bytes.NewReader(b))
+	require.Nil(t, err)
+	assert.True(t, report.Intact())
+	assert.Equal(t, b, repaired)
+
+	// The third point starts after the header, the first point and 9+24 bits of the second one.
+	const third = 32 + 14 + 64 + 9 + 24
+	points, report, err = gorilla.Salvage(bytes.NewReader(b[:third/8+1]))
+	require.Nil(t, err)
+	assert.Equal(t, gorilla.SalvageReport{
+		Header:   1600000000,
+		Bytes:    third/8 + 1,
+		Points:   2,
+		Problem:  "truncated block",
+		Offset:   third,
+		LostBits: 8 - third%8,
+	}, report)
+	assert.Len(t, points, 2)
+	assert.Equal(t, "recovered 2 points, lost 1 bits from bit 143: truncated block", report.String())
+
+	_, _, err = gorilla.Salvage(bytes.NewReader(b[:3]))
+	assert.True(t, errors.Is(err, gorilla.ErrTruncatedBlock), err)
+
+	_, _, err = gorilla.Salvage(iotest.ErrReader(errors.New("disk error")))
+	assert.EqualError(t, err, "disk error")
+}
+
+func Test_Repair_repeatedTimestamp(t *testing.T) {
+	// tsdb.Store appends points at the same timestamp, which are no damage.
+	want := []point{{1600000200, 1}, {1600000260, 2}, {1600000260, 3}, {1600000320, 4}}
+	b := compress(t, 1600000200, want)
+
+	repaired, report, err := gorilla.Repair(bytes.NewReader(b))
+	require.Nil(t, err)
+	assert.True(t, report.Intact(), report)
+	assert.Equal(t, 4, report.Points)
+	assert.Equal(t, b, repaired)
+	_, points := decompress(t, repaired)
+	assert.Equal(t, want, points)
+}
+
+func Test_Repair_damaged(t *testing.T) {
+	want := compress(t, 1600000000, []point{{1600000000, 1}, {1600000060, 2}, {1600000120, 2}, {1600000185, 2.5}, {1600000240, -1}})
+	_, original := decompress(t, want)
+
+	check := func(t *testing.T, b []byte) {
+		t.Helper()
+		repaired, report, err := gorilla.Repair(bytes.NewReader(b))
+		require.Nil(t, err)
+		require.Nil(t, gorilla.Block(repaired).Validate())
+		_, points := decompress(t, repaired)
+		assert.Len(t, points, report.Points)
+		if !report.Intact() {
+			assert.Equal(t, uint64(len(b)*8), report.Offset+report.LostBits)
+		}
+	}
+	for n := 4; n < len(want); n++ {
+		check(t, want[:n])
+		repaired, _, err := gorilla.Repair(bytes.NewReader(want[:n]))
+		require.Nil(t, err)
+		_, points := decompress(t, repaired)
+		for i, p := range points {
+			assert.Equal(t, original[i], p, "truncated at %d", n)
+		}
+	}
+	for bit := 32; bit < len(want)*8; bit++ {
+		b := append([]byte(nil), want...)
+		b[bit/8] ^= 0x80 >> (bit % 8)
+		check(t, b)
+	}
+}
@@ -0,0 +1,3 @@
+// Package scrape provides scraping of the Prometheus and OpenMetrics text
+// formats into gorilla blocks.
+package scrape
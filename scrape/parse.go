@@ -0,0 +1,215 @@
+package scrape
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/remote"
+	"github.com/keisku/gorilla/tsdb"
+)
+
+// Options configures Ingest.
+type Options struct {
+	// OpenMetrics tells the input is in the OpenMetrics text format, whose
+	// timestamps are in seconds, rather than the Prometheus text format,
+	// whose timestamps are in milliseconds.
+	OpenMetrics bool
+	// Time is the timestamp of samples without one, e.g. the scrape time.
+	// Defaults to the time Parse is called.
+	Time time.Time
+}
+
+// Sample is a sample of an exposition.
+type Sample struct {
+	Labels []remote.Label
+	T      uint32
+	V      float64
+}
+
+// Ingest parses the exposition in r and appends every sample to a, including
+// the _bucket, _sum and _count samples of histograms and summaries. Each sample
+// is appended to the series named by remote.SeriesName, with its timestamp
+// truncated to seconds. Nothing is appended if the exposition is malformed.
+func Ingest(r io.Reader, a tsdb.Appender, opts Options) error {
+	samples, err := Parse(r, opts)
+	if err != nil {
+		return err
+	}
+	var failed int
+	var firstErr error
+	for _, s := range samples {
+		series := remote.SeriesName(s.Labels)
+		if err := a.Append(series, s.T, s.V); err != nil {
+			if failed == 0 {
+				firstErr = fmt.Errorf("failed to append to %s: %w", series, err)
+			}
+			failed++
+		}
+	}
+	if 0 < failed {
+		return fmt.Errorf("failed to append %d of %d samples: %w", failed, len(samples), firstErr)
+	}
+	return nil
+}
+
+// Parse parses the exposition in r.
+func Parse(r io.Reader, opts Options) ([]Sample, error) {
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
+	}
+	now, err := gorilla.UnixSeconds(opts.Time.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("invalid time: %w", err)
+	}
+	var samples []Sample
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read line %d: %w", n, err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "# EOF" && opts.OpenMetrics {
+			return samples, nil
+		}
+		if line != "" && !strings.HasPrefix(line, "#") {
+			s, perr := parseSample(line, now, opts.OpenMetrics)
+			if perr != nil {
+				return nil, fmt.Errorf("line %d: %w", n, perr)
+			}
+			samples = append(samples, s)
+		}
+		if err != nil {
+			return samples, nil
+		}
+	}
+}
+
+// parseSample parses a line of `name[{label="value",...}] value [timestamp]`.
+func parseSample(line string, now uint32, openMetrics bool) (Sample, error) {
+	s := Sample{T: now}
+	i := 0
+	for i < len(line) && isNameChar(line[i], i == 0) {
+		i++
+	}
+	if i == 0 {
+		return s, errors.New("missing metric name")
+	}
+	s.Labels = append(s.Labels, remote.Label{Name: remote.MetricName, Value: line[:i]})
+	rest := line[i:]
+	if strings.HasPrefix(rest, "{") {
+		labels, n, err := parseLabels(rest)
+		if err != nil {
+			return s, err
+		}
+		s.Labels = append(s.Labels, labels...)
+		rest = rest[n:]
+	}
+	if openMetrics {
+		// Drop the exemplar.
+		if i := strings.Index(rest, " # "); 0 <= i {
+			rest = rest[:i]
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || 2 < len(fields) {
+		return s, fmt.Errorf("expected value and optional timestamp: %q", rest)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid value: %w", err)
+	}
+	s.V = v
+	if len(fields) == 2 {
+		if s.T, err = parseTimestamp(fields[1], openMetrics); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+func parseTimestamp(ts string, openMetrics bool) (uint32, error) {
+	if openMetrics {
+		f, err := strconv.ParseFloat(ts, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, fmt.Errorf("invalid timestamp: %s", ts)
+		}
+		return gorilla.UnixSeconds(int64(math.Floor(f)))
+	}
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return gorilla.UnixSeconds(ms / 1000)
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c == ':' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+// parseLabels parses `{label="value",...}` at the start of s and returns
+// the labels and the length of the label set.
+func parseLabels(s string) ([]remote.Label, int, error) {
+	var labels []remote.Label
+	i := 1
+	for {
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i < len(s) && s[i] == '}' {
+			return labels, i + 1, nil
+		}
+		start := i
+		for i < len(s) && isNameChar(s[i], i == start) {
+			i++
+		}
+		if i == start {
+			return nil, 0, errors.New("invalid label name")
+		}
+		name := s[start:i]
+		if !strings.HasPrefix(s[i:], `="`) {
+			return nil, 0, fmt.Errorf("expected =\" after label %s", name)
+		}
+		i += 2
+		var value strings.Builder
+		for {
+			if len(s) <= i {
+				return nil, 0, fmt.Errorf("unterminated value of label %s", name)
+			}
+			c := s[i]
+			i++
+			if c == '"' {
+				break
+			}
+			if c == '\\' && i < len(s) {
+				switch s[i] {
+				case 'n':
+					c = '\n'
+				case '\\', '"':
+					c = s[i]
+				default:
+					return nil, 0, fmt.Errorf("invalid escape in value of label %s", name)
+				}
+				i++
+			}
+			value.WriteByte(c)
+		}
+		labels = append(labels, remote.Label{Name: name, Value: value.String()})
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i < len(s) && s[i] == ',' {
+			i++
+		} else if i < len(s) && s[i] != '}' {
+			return nil, 0, fmt.Errorf("expected , or } after label %s", name)
+		}
+	}
+}
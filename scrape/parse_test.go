@@ -0,0 +1,106 @@
+package scrape_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keisku/gorilla/remote"
+	"github.com/keisku/gorilla/scrape"
+	"github.com/keisku/gorilla/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const prometheusText = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1600000000000
+http_requests_total{method="post",code="400"}    3 1600000000000
+
+# A histogram.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.05"} 24054
+http_request_duration_seconds_bucket{le="+Inf"} 144320
+http_request_duration_seconds_sum 53423
+http_request_duration_seconds_count 144320
+go_goroutines +Inf
+escaped{path="C:\\dir\"x\"\n",} NaN
+`
+
+func Test_Parse_Prometheus(t *testing.T) {
+	now := time.Unix(1600000015, 0)
+	samples, err := scrape.Parse(strings.NewReader(prometheusText), scrape.Options{Time: now})
+	require.Nil(t, err)
+	require.Len(t, samples, 8)
+
+	assert.Equal(t, scrape.Sample{
+		Labels: []remote.Label{{Name: remote.MetricName, Value: "http_requests_total"}, {Name: "method", Value: "post"}, {Name: "code", Value: "200"}},
+		T:      1600000000,
+		V:      1027,
+	}, samples[0])
+	assert.Equal(t, `http_request_duration_seconds_bucket{le="+Inf"}`, remote.SeriesName(samples[3].Labels))
+	assert.Equal(t, uint32(1600000015), samples[3].T)
+	assert.Equal(t, "http_request_duration_seconds_count", remote.SeriesName(samples[5].Labels))
+	assert.True(t, math.IsInf(samples[6].V, 1))
+	assert.Equal(t, []remote.Label{{Name: remote.MetricName, Value: "escaped"}, {Name: "path", Value: "C:\\dir\"x\"\n"}}, samples[7].Labels)
+	assert.True(t, math.IsNaN(samples[7].V))
+}
+
+func Test_Parse_OpenMetrics(t *testing.T) {
+	input := `# TYPE foo counter
+# UNIT foo seconds
+foo_total{a="b"} 17.5 1600000000.250 # {trace_id="abc"} 1.0 1599999999.0
+foo_created{a="b"} 1599990000
+# EOF
+ignored 1
+`
+	samples, err := scrape.Parse(strings.NewReader(input), scrape.Options{OpenMetrics: true, Time: time.Unix(1600000015, 0)})
+	require.Nil(t, err)
+	require.Len(t, samples, 2)
+	assert.Equal(t, uint32(1600000000), samples[0].T)
+	assert.Equal(t, 17.5, samples[0].V)
+	assert.Equal(t, uint32(1600000015), samples[1].T)
+}
+
+func Test_Parse_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"1foo 1",
+		`foo{a="b" 1`,
+		`foo{a=b} 1`,
+		`foo{a="\x"} 1`,
+		"foo",
+		"foo bar",
+		"foo 1 2 3",
+		"foo 1 -5",
+	} {
+		_, err := scrape.Parse(strings.NewReader(input), scrape.Options{Time: time.Unix(1600000000, 0)})
+		assert.NotNil(t, err, input)
+	}
+}
+
+func Test_Parse_ZeroTime(t *testing.T) {
+	samples, err := scrape.Parse(strings.NewReader("up 1 1600000000000\n"), scrape.Options{})
+	require.Nil(t, err)
+	require.Len(t, samples, 1)
+	assert.Equal(t, uint32(1600000000), samples[0].T)
+
+	before := time.Now().Unix()
+	samples, err = scrape.Parse(strings.NewReader("up 1\n"), scrape.Options{})
+	require.Nil(t, err)
+	require.Len(t, samples, 1)
+	assert.LessOrEqual(t, before, int64(samples[0].T))
+}
+
+func Test_Ingest(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	require.Nil(t, scrape.Ingest(strings.NewReader(prometheusText), s, scrape.Options{Time: time.Unix(1600000015, 0)}))
+	assert.Contains(t, s.Series(), `http_requests_total{code="400",method="post"}`)
+	assert.Len(t, s.Series(), 8)
+
+	// Out of order samples are reported after the others are appended.
+	err = scrape.Ingest(strings.NewReader("go_goroutines 1 1500000000000\nup 1"), s, scrape.Options{Time: time.Unix(1600000030, 0)})
+	assert.NotNil(t, err)
+	assert.Contains(t, s.Series(), "up")
+}
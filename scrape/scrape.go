@@ -0,0 +1,57 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/keisku/gorilla/tsdb"
+)
+
+const acceptHeader = "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5"
+
+// Scraper scrapes HTTP targets exposing metrics in the Prometheus or
+// OpenMetrics text format and appends the samples to an Appender.
+type Scraper struct {
+	appender tsdb.Appender
+	client   *http.Client
+}
+
+// NewScraper returns a Scraper appending samples to a.
+// client defaults to http.DefaultClient when nil.
+func NewScraper(a tsdb.Appender, client *http.Client) *Scraper {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Scraper{appender: a, client: client}
+}
+
+// Scrape scrapes the target URL once. Samples without timestamps are
+// stamped with the time the scrape started.
+func (s *Scraper) Scrape(ctx context.Context, target string) error {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", acceptHeader)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to scrape %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to scrape %s: %s", target, resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	opts := Options{
+		OpenMetrics: mediaType == "application/openmetrics-text",
+		Time:        start,
+	}
+	if err := Ingest(resp.Body, s.appender, opts); err != nil {
+		return fmt.Errorf("failed to ingest %s: %w", target, err)
+	}
+	return nil
+}
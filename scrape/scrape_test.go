@@ -0,0 +1,55 @@
+package scrape_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keisku/gorilla/scrape"
+	"github.com/keisku/gorilla/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Scraper_Scrape(t *testing.T) {
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/openmetrics":
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+			w.Write([]byte("up 1 1600000000.5\nlast 2\n# EOF\n"))
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			w.Write([]byte("up 1 1600000000500\nlast 2\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer exporter.Close()
+
+	for _, path := range []string{"/openmetrics", "/text"} {
+		t.Run(path, func(t *testing.T) {
+			s, err := tsdb.NewStore(tsdb.Options{})
+			require.Nil(t, err)
+			before := uint32(time.Now().Unix())
+			require.Nil(t, scrape.NewScraper(s, nil).Scrape(context.Background(), exporter.URL+path))
+			after := uint32(time.Now().Unix())
+
+			it := s.Query("up", 0, 1<<32-1)
+			require.True(t, it.Next())
+			ts, v := it.At()
+			assert.Equal(t, uint32(1600000000), ts)
+			assert.Equal(t, 1.0, v)
+
+			it = s.Query("last", 0, 1<<32-1)
+			require.True(t, it.Next())
+			ts, _ = it.At()
+			assert.True(t, before <= ts && ts <= after)
+		})
+	}
+
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	assert.NotNil(t, scrape.NewScraper(s, nil).Scrape(context.Background(), exporter.URL+"/missing"))
+}
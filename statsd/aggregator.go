@@ -0,0 +1,194 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// aggregator aggregates the metrics received within a flush interval.
+type aggregator struct {
+	counters map[string]float64
+	gauges   map[string]float64
+	// updated has the gauges updated within the interval.
+	updated map[string]struct{}
+	timers  map[string]*timer
+	sets    map[string]map[string]struct{}
+	// types has the type each name was first received with. Metrics of
+	// different types are flushed to the same series, e.g. <name>.count,
+	// so a name cannot be used by another type.
+	types map[string]string
+	// series has the name of the metric each series is flushed from, as
+	// metrics of different names can also be flushed to the same series, e.g.
+	// a gauge x.count and a counter x.
+	series map[string]string
+}
+
+// suffixes are the suffixes of the series each type is flushed to.
+var suffixes = map[string][]string{
+	"c":  {".count", ".rate"},
+	"g":  {""},
+	"ms": {".count", ".min", ".max", ".mean", ".sum", ".p90"},
+	"s":  {".count"},
+}
+
+type timer struct {
+	values []float64
+	// count is the number of values scaled by their sample rates.
+	count float64
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+		updated:  make(map[string]struct{}),
+		timers:   make(map[string]*timer),
+		sets:     make(map[string]map[string]struct{}),
+		types:    make(map[string]string),
+		series:   make(map[string]string),
+	}
+}
+
+// add aggregates a line of `name:value|type[|@rate][|#tags]`.
+// Tags are ignored.
+func (a *aggregator) add(line string) error {
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 {
+		return fmt.Errorf("missing type: %q", line)
+	}
+	colon := strings.LastIndexByte(parts[0], ':')
+	if colon <= 0 {
+		return fmt.Errorf("missing name: %q", line)
+	}
+	name, value, typ := parts[0][:colon], parts[0][colon+1:], parts[1]
+	rate := 1.0
+	for _, p := range parts[2:] {
+		if strings.HasPrefix(p, "@") {
+			r, err := strconv.ParseFloat(p[1:], 64)
+			if err != nil || r <= 0 || 1 < r {
+				return fmt.Errorf("invalid sample rate: %q", line)
+			}
+			rate = r
+		}
+	}
+
+	if typ == "h" || typ == "d" {
+		typ = "ms"
+	}
+	switch typ {
+	case "c", "g", "ms", "s":
+	default:
+		return errors.New("unknown metric type: " + typ)
+	}
+	if t, ok := a.types[name]; ok && t != typ {
+		return fmt.Errorf("metric %s of type %s is received as %s: %q", name, t, typ, line)
+	} else if !ok {
+		for _, suffix := range suffixes[typ] {
+			if other, ok := a.series[name+suffix]; ok {
+				return fmt.Errorf("series %s of metric %s is flushed from metric %s: %q", name+suffix, name, other, line)
+			}
+		}
+	}
+
+	if typ == "s" {
+		a.register(name, typ)
+		set, ok := a.sets[name]
+		if !ok {
+			set = make(map[string]struct{})
+			a.sets[name] = set
+		}
+		set[value] = struct{}{}
+		return nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("invalid value: %q", line)
+	}
+	a.register(name, typ)
+	switch typ {
+	case "c":
+		a.counters[name] += v / rate
+	case "g":
+		// A signed value changes the gauge rather than setting it.
+		if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+			a.gauges[name] += v
+		} else {
+			a.gauges[name] = v
+		}
+		a.updated[name] = struct{}{}
+	case "ms":
+		tm, ok := a.timers[name]
+		if !ok {
+			tm = &timer{}
+			a.timers[name] = tm
+		}
+		tm.values = append(tm.values, v)
+		tm.count += 1 / rate
+	}
+	return nil
+}
+
+// register records the type of the metric name and the series it is flushed to.
+func (a *aggregator) register(name, typ string) {
+	if _, ok := a.types[name]; ok {
+		return
+	}
+	a.types[name] = typ
+	for _, suffix := range suffixes[typ] {
+		a.series[name+suffix] = name
+	}
+}
+
+type point struct {
+	series string
+	v      float64
+}
+
+// flush returns the points aggregated in an interval of seconds and
+// resets the aggregator. Gauges keep their values for later changes but are
+// flushed only when updated.
+//
+// Counters are flushed as <name>.count and <name>.rate per second, timers as
+// <name>.count, .min, .max, .mean, .sum and .p90, sets as <name>.count and gauges as <name>.
+// The counts of counters and timers are scaled by the sample rates, while the
+// other statistics of timers are of the sampled values.
+func (a *aggregator) flush(interval float64) []point {
+	var points []point
+	for name, c := range a.counters {
+		points = append(points, point{name + ".count", c}, point{name + ".rate", c / interval})
+	}
+	for name, tm := range a.timers {
+		vs := tm.values
+		sort.Float64s(vs)
+		var sum float64
+		for _, v := range vs {
+			sum += v
+		}
+		p90 := vs[int(math.Ceil(0.9*float64(len(vs))))-1]
+		points = append(points,
+			point{name + ".count", tm.count},
+			point{name + ".min", vs[0]},
+			point{name + ".max", vs[len(vs)-1]},
+			point{name + ".mean", sum / float64(len(vs))},
+			point{name + ".sum", sum},
+			point{name + ".p90", p90},
+		)
+	}
+	for name, set := range a.sets {
+		points = append(points, point{name + ".count", float64(len(set))})
+	}
+	for name := range a.updated {
+		points = append(points, point{name, a.gauges[name]})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].series < points[j].series })
+
+	a.counters = make(map[string]float64)
+	a.updated = make(map[string]struct{})
+	a.timers = make(map[string]*timer)
+	a.sets = make(map[string]map[string]struct{})
+	return points
+}
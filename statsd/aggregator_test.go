@@ -0,0 +1,82 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_aggregator(t *testing.T) {
+	a := newAggregator()
+	for _, line := range []string{
+		"hits:1|c",
+		"hits:2|c|@0.5",
+		"hits:1|c|#env:prod",
+		"latency:10|ms",
+		"latency:30|ms",
+		"latency:20|ms|@0.1",
+		"temp:20|g",
+		"temp:+5|g",
+		"temp:-2|g",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+	} {
+		require.Nil(t, a.add(line), line)
+	}
+	assert.Equal(t, []point{
+		{"hits.count", 6},
+		{"hits.rate", 0.6},
+		{"latency.count", 12},
+		{"latency.max", 30},
+		{"latency.mean", 20},
+		{"latency.min", 10},
+		{"latency.p90", 30},
+		{"latency.sum", 60},
+		{"temp", 23},
+		{"users.count", 2},
+	}, a.flush(10))
+
+	// Only the updated metrics are flushed, while gauges keep their values.
+	require.Nil(t, a.add("temp:+1|g"))
+	assert.Equal(t, []point{{"temp", 24}}, a.flush(10))
+	assert.Empty(t, a.flush(10))
+}
+
+func Test_aggregator_Invalid(t *testing.T) {
+	a := newAggregator()
+	for _, line := range []string{
+		"hits",
+		":1|c",
+		"hits:1",
+		"hits:x|c",
+		"hits:1|c|@2",
+		"hits:1|x",
+	} {
+		assert.NotNil(t, a.add(line), line)
+	}
+}
+
+func Test_aggregator_TypeCollision(t *testing.T) {
+	a := newAggregator()
+	require.Nil(t, a.add("hits:1|c"))
+	assert.NotNil(t, a.add("hits:10|ms"))
+	assert.NotNil(t, a.add("hits:alice|s"))
+	assert.NotNil(t, a.add("hits:1|g"))
+	assert.Equal(t, []point{{"hits.count", 1}, {"hits.rate", 0.1}}, a.flush(10))
+
+	// The type of a name is kept across intervals.
+	assert.NotNil(t, a.add("hits:10|ms"))
+	require.Nil(t, a.add("latency:10|h"))
+	require.Nil(t, a.add("latency:20|ms"))
+
+	// Metrics of different names cannot be flushed to the same series either.
+	require.Nil(t, a.add("x.count:1|g"))
+	assert.NotNil(t, a.add("x:1|c"))
+	assert.NotNil(t, a.add("x:alice|s"))
+	require.Nil(t, a.add("y:1|c"))
+	assert.NotNil(t, a.add("y.rate:1|g"))
+	assert.NotNil(t, a.add("latency.p90:1|g"))
+	require.Nil(t, a.add("x:1|g"))
+}
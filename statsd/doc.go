@@ -0,0 +1,3 @@
+// Package statsd provides a StatsD listener aggregating metrics into points
+// of gorilla-backed series per flush interval.
+package statsd
@@ -0,0 +1,113 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keisku/gorilla/tsdb"
+)
+
+// DefaultFlushInterval is the flush interval used when Server.FlushInterval is zero.
+const DefaultFlushInterval = 10 * time.Second
+
+// maxPacketSize is the largest UDP payload.
+const maxPacketSize = 65535
+
+// Server receives StatsD metrics over UDP, aggregates them per flush interval
+// and appends the aggregates to series stamped with the flush time.
+// Metrics not updated within an interval are not appended.
+type Server struct {
+	// Appender receives the aggregated points.
+	Appender tsdb.Appender
+	// FlushInterval is the interval metrics are aggregated over.
+	// Defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+	// ErrorHandler is called with the errors of malformed metrics and failed
+	// appends, which are skipped. Nil ignores them.
+	ErrorHandler func(error)
+}
+
+// ListenAndServe listens on the UDP address and serves until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return s.Serve(ctx, conn)
+}
+
+// Serve reads metrics from conn until ctx is done. It then closes conn,
+// flushes the metrics aggregated so far and returns nil.
+func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	interval := s.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	var mu sync.Mutex
+	agg := newAggregator()
+	flush := func() {
+		mu.Lock()
+		points := agg.flush(interval.Seconds())
+		mu.Unlock()
+		t := uint32(time.Now().Unix())
+		for _, p := range points {
+			if err := s.Appender.Append(p.series, t, p.v); err != nil {
+				s.handleError(fmt.Errorf("failed to append to %s: %w", p.series, err))
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flush()
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			close(stop)
+			<-flushed
+			flush()
+			if ctx.Err() != nil && errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to read: %w", err)
+		}
+		mu.Lock()
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if err := agg.add(line); err != nil {
+				s.handleError(err)
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+func (s *Server) handleError(err error) {
+	if s.ErrorHandler != nil {
+		s.ErrorHandler(err)
+	}
+}
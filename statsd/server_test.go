@@ -0,0 +1,92 @@
+package statsd_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keisku/gorilla/statsd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type appender struct {
+	mu     sync.Mutex
+	points map[string]float64
+}
+
+func (a *appender) Append(series string, t uint32, v float64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.points[series] += v
+	return nil
+}
+
+func (a *appender) get(series string) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.points[series]
+}
+
+func Test_Server(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	a := &appender{points: make(map[string]float64)}
+	s := &statsd.Server{Appender: a, FlushInterval: 10 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, conn) }()
+
+	c, err := net.Dial("udp", conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	_, err = c.Write([]byte("hits:1|c\nhits:2|c\nlatency:5|ms"))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool { return a.get("hits.count") == 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, 5.0, a.get("latency.max"))
+
+	cancel()
+	require.Nil(t, <-done)
+}
+
+func Test_Server_FlushOnShutdown(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	a := &appender{points: make(map[string]float64)}
+	var mu sync.Mutex
+	var received int
+	s := &statsd.Server{
+		Appender:      a,
+		FlushInterval: time.Hour,
+		ErrorHandler: func(error) {
+			mu.Lock()
+			received++
+			mu.Unlock()
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, conn) }()
+
+	c, err := net.Dial("udp", conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	_, err = c.Write([]byte("temp:21.5|g"))
+	require.Nil(t, err)
+	// A malformed metric after the gauge tells it has been aggregated.
+	_, err = c.Write([]byte("broken"))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return received == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	require.Nil(t, <-done)
+	assert.Equal(t, 21.5, a.get("temp"))
+}
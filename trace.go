@@ -0,0 +1,97 @@
+package gorilla
+
+// valueCase is how a value is encoded.
+type valueCase int
+
+const (
+	// valueFirst is the first value stored with no compression.
+	valueFirst valueCase = iota
+	// valueSame is a value identical to the previous one, stored as '0'.
+	valueSame
+	// valueReuse is a value whose XOR fits in the previous meaningful bits window, stored as '10'.
+	valueReuse
+	// valueNew is a value whose XOR is stored with a new window, stored as '11'.
+	valueNew
+)
+
+// pointTrace records how a point is encoded.
+type pointTrace struct {
+	// offset is the bit offset where the point starts.
+	offset uint64
+	// dodBits is the number of bits of the delta of delta following its control
+	// bits, or firstDeltaBits for the delta of the first point.
+	dodBits    uint
+	dodPayload uint64
+	dod        int64
+
+	valueCase valueCase
+	// leadingZeros and trailingZeros are the window of the meaningful bits
+	// of valueReuse and valueNew.
+	leadingZeros  uint8
+	trailingZeros uint8
+	// valuePayload is the meaningful bits of the XOR, or the raw bits of valueFirst.
+	valuePayload uint64
+
+	t uint32
+	v float64
+}
+
+// dodControl returns the control bits preceding the delta of delta.
+func (p *pointTrace) dodControl() string {
+	switch p.dodBits {
+	case 0:
+		return "0"
+	case 7:
+		return "10"
+	case 9:
+		return "110"
+	case 12:
+		return "1110"
+	case 32:
+		return "1111"
+	}
+	return ""
+}
+
+// valueControl returns the control bits preceding the value.
+func (p *pointTrace) valueControl() string {
+	switch p.valueCase {
+	case valueSame:
+		return "0"
+	case valueReuse:
+		return "10"
+	case valueNew:
+		return "11"
+	}
+	return ""
+}
+
+// significantBits returns the number of meaningful bits of the value.
+func (p *pointTrace) significantBits() uint8 {
+	switch p.valueCase {
+	case valueFirst:
+		return 64
+	case valueSame:
+		return 0
+	}
+	return 64 - p.leadingZeros - p.trailingZeros
+}
+
+// traceAll decompresses the points of d, calling fn with the trace of each point.
+// It returns the bit offset where the decompression stopped and the error
+// stopping it, which is errEndOfBlock at the finish marker.
+func (d *Decompressor) traceAll(fn func(*pointTrace)) (offset uint64, err error) {
+	for {
+		p := &pointTrace{offset: d.br.read}
+		d.trace = p
+		if d.t == 0 {
+			p.t, p.v, err = d.decompressFirst()
+		} else {
+			p.t, p.v, err = d.decompress()
+		}
+		if err != nil {
+			return p.offset, err
+		}
+		fn(p)
+	}
+}
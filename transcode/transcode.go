@@ -0,0 +1,178 @@
+// Package transcode converts series between wire variants of Gorilla compression:
+// the blocks of this module, the XOR chunks of Prometheus, and Variant, the
+// blocks of a configured gorilla.Encoding.
+package transcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/internal/xorchunk"
+)
+
+// ErrMismatch is returned by Transcode when the transcoded series does not
+// decode to the points of the source.
+var ErrMismatch = errors.New("transcoded points mismatch")
+
+// Codec encodes and decodes series in a wire variant.
+type Codec interface {
+	// Name identifies the variant.
+	Name() string
+	Encode(points []gorilla.Point) ([]byte, error)
+	Decode(b []byte) ([]gorilla.Point, error)
+}
+
+// Transcode decodes b with from and encodes the points with to. It verifies
+// that the result decodes with to into the same points, comparing values
+// bitwise so that NaNs match.
+func Transcode(b []byte, from, to Codec) ([]byte, error) {
+	points, err := from.Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", from.Name(), err)
+	}
+	out, err := to.Encode(points)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", to.Name(), err)
+	}
+	decoded, err := to.Decode(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transcoded %s: %w", to.Name(), err)
+	}
+	if len(decoded) != len(points) {
+		return nil, fmt.Errorf("%w: %d points decoded from %d", ErrMismatch, len(decoded), len(points))
+	}
+	for i, p := range points {
+		if decoded[i].T != p.T || math.Float64bits(decoded[i].V) != math.Float64bits(p.V) {
+			return nil, fmt.Errorf("%w: point %d is %v, want %v", ErrMismatch, i, decoded[i], p)
+		}
+	}
+	return out, nil
+}
+
+// Gorilla is the codec of the blocks of this module: second timestamps whose
+// delta of delta is bucketed in 7, 9, 12 and 32 bits, with a 32-bit header and
+// a finish marker. The header of encoded blocks is the first timestamp.
+var Gorilla Codec = gorillaCodec{}
+
+type gorillaCodec struct{}
+
+func (gorillaCodec) Name() string {
+	return "gorilla"
+}
+
+func (gorillaCodec) Encode(points []gorilla.Point) ([]byte, error) {
+	return encodeBlock(points, gorilla.Encoding{})
+}
+
+func (gorillaCodec) Decode(b []byte) ([]gorilla.Point, error) {
+	return decodeBlock(b, gorilla.Encoding{})
+}
+
+// Variant is the codec of blocks compressed with the Encoding, e.g. by a
+// Gorilla implementation storing timestamps in another unit or bucketing the
+// delta of delta differently. The header of encoded blocks is the first timestamp.
+type Variant struct {
+	gorilla.Encoding
+}
+
+func (v Variant) Name() string {
+	return "variant(" + v.Encoding.String() + ")"
+}
+
+func (v Variant) Encode(points []gorilla.Point) ([]byte, error) {
+	return encodeBlock(points, v.Encoding)
+}
+
+func (v Variant) Decode(b []byte) ([]gorilla.Point, error) {
+	return decodeBlock(b, v.Encoding)
+}
+
+func encodeBlock(points []gorilla.Point, enc gorilla.Encoding) ([]byte, error) {
+	var header uint32
+	if 0 < len(points) {
+		header = points[0].T
+	}
+	buf := new(bytes.Buffer)
+	c, finish, err := gorilla.NewCompressorWithEncoding(buf, header, enc)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range points {
+		if err := c.Compress(p.T, p.V); err != nil {
+			return nil, fmt.Errorf("point %d: %w", i, err)
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeBlock(b []byte, enc gorilla.Encoding) ([]gorilla.Point, error) {
+	d, _, err := gorilla.NewDecompressorWithEncoding(bytes.NewReader(b), enc)
+	if err != nil {
+		return nil, err
+	}
+	var points []gorilla.Point
+	iter := d.Iterator()
+	for iter.Next() {
+		t, v := iter.At()
+		points = append(points, gorilla.Point{T: t, V: v})
+	}
+	return points, iter.Err()
+}
+
+// Prometheus is the codec of the XOR chunks of Prometheus: millisecond
+// timestamps whose delta of delta is bucketed in 14, 17, 20 and 64 bits,
+// framed by the number of samples instead of a header and a finish marker.
+type Prometheus struct {
+	// Truncate truncates timestamps to seconds on decoding, which otherwise
+	// fails for timestamps with milliseconds.
+	Truncate bool
+}
+
+func (Prometheus) Name() string {
+	return "prometheus"
+}
+
+func (Prometheus) Encode(points []gorilla.Point) ([]byte, error) {
+	if xorchunk.MaxSamples < len(points) {
+		return nil, fmt.Errorf("%d points exceed %d samples of a chunk", len(points), xorchunk.MaxSamples)
+	}
+	c := xorchunk.NewEncoder()
+	for _, p := range points {
+		c.Append(int64(p.T)*1000, p.V)
+	}
+	return c.Bytes(), nil
+}
+
+func (p Prometheus) Decode(b []byte) ([]gorilla.Point, error) {
+	var points []gorilla.Point
+	var err error
+	decodeErr := xorchunk.Decode(b, func(t int64, v float64) {
+		if err != nil {
+			return
+		}
+		if t%1000 != 0 && !p.Truncate {
+			err = fmt.Errorf("timestamp %d ms is not in whole seconds", t)
+			return
+		}
+		s, serr := gorilla.UnixSeconds(t / 1000)
+		if serr != nil {
+			err = fmt.Errorf("timestamp %d ms: %w", t, serr)
+			return
+		}
+		if 0 < len(points) && s <= points[len(points)-1].T {
+			err = fmt.Errorf("timestamp %d ms is not increasing in seconds", t)
+			return
+		}
+		points = append(points, gorilla.Point{T: s, V: v})
+	})
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return points, err
+}
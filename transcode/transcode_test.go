@@ -0,0 +1,157 @@
+package transcode_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/internal/xorchunk"
+	"github.com/keisku/gorilla/transcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var points = []gorilla.Point{
+	{T: 1600000000, V: 1},
+	{T: 1600000015, V: 1},
+	{T: 1600000030, V: 2.5},
+	{T: 1600000045, V: math.NaN()},
+	{T: 1600000061, V: -3},
+	{T: 1600005000, V: math.Inf(1)},
+}
+
+func Test_Transcode(t *testing.T) {
+	block, err := transcode.Gorilla.Encode(points)
+	require.Nil(t, err)
+
+	chunk, err := transcode.Transcode(block, transcode.Gorilla, transcode.Prometheus{})
+	require.Nil(t, err)
+	got, err := transcode.Prometheus{}.Decode(chunk)
+	require.Nil(t, err)
+	require.Len(t, got, len(points))
+	assert.Equal(t, points[:3], got[:3])
+	assert.True(t, math.IsNaN(got[3].V))
+
+	back, err := transcode.Transcode(chunk, transcode.Prometheus{}, transcode.Gorilla)
+	require.Nil(t, err)
+	assert.Equal(t, block, back)
+}
+
+func Test_Prometheus_Decode(t *testing.T) {
+	// Encoded by chunkenc.XORChunk of Prometheus v2.45.0.
+	chunk, err := hex.DecodeString("00068080f4f6905d3ff00000000000009875309bffd20fa301d001ee2b46082071735940")
+	require.Nil(t, err)
+	got, err := transcode.Prometheus{}.Decode(chunk)
+	require.Nil(t, err)
+	assert.Equal(t, []gorilla.Point{
+		{T: 1600000000, V: 1},
+		{T: 1600000015, V: 1},
+		{T: 1600000030, V: 2.5},
+		{T: 1600000045, V: 2.5},
+		{T: 1600000061, V: -3},
+		{T: 1600000062, V: 1e9},
+	}, got)
+
+	c := xorchunk.NewEncoder()
+	c.Append(1600000000000, 1)
+	c.Append(1600000015250, 2)
+	_, err = transcode.Prometheus{}.Decode(c.Bytes())
+	assert.EqualError(t, err, "timestamp 1600000015250 ms is not in whole seconds")
+	got, err = transcode.Prometheus{Truncate: true}.Decode(c.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, []gorilla.Point{{T: 1600000000, V: 1}, {T: 1600000015, V: 2}}, got)
+
+	c.Append(1600000015750, 3)
+	_, err = transcode.Prometheus{Truncate: true}.Decode(c.Bytes())
+	assert.NotNil(t, err, "truncated timestamps must be increasing")
+}
+
+func Test_Variant(t *testing.T) {
+	block := mustEncode(t, points)
+	for _, v := range []transcode.Variant{
+		{},
+		{Encoding: gorilla.Encoding{Unit: time.Millisecond, FirstDeltaBits: 27, Buckets: []int{14, 17, 20, 64}}},
+		{Encoding: gorilla.Encoding{Unit: time.Nanosecond, Buckets: []int{64}}},
+		{Encoding: gorilla.Encoding{Buckets: []int{1, 2, 3, 4, 8, 16, 33}}},
+		{Encoding: gorilla.Encoding{Values: gorilla.RawValues}},
+	} {
+		t.Run(v.Name(), func(t *testing.T) {
+			b, err := transcode.Transcode(block, transcode.Gorilla, v)
+			require.Nil(t, err)
+			back, err := transcode.Transcode(b, v, transcode.Gorilla)
+			require.Nil(t, err)
+			assert.Equal(t, block, back)
+		})
+	}
+	assert.Equal(t, "variant(unit=1s first-delta=14 buckets=7,9,12,32 values=xor)", transcode.Variant{}.Name())
+
+	b, err := transcode.Variant{}.Encode(nil)
+	require.Nil(t, err)
+	got, err := transcode.Variant{}.Decode(b)
+	require.Nil(t, err)
+	assert.Empty(t, got)
+
+	// Points at the same timestamp are kept like in the blocks of this module.
+	same := []gorilla.Point{{T: 1600000000, V: 1}, {T: 1600000000, V: 2}, {T: 1600000060, V: 3}}
+	ms := transcode.Variant{Encoding: gorilla.Encoding{Unit: time.Millisecond, Buckets: []int{14, 17, 20, 64}}}
+	b, err = transcode.Transcode(mustEncode(t, same), transcode.Gorilla, ms)
+	require.Nil(t, err)
+	got, err = ms.Decode(b)
+	require.Nil(t, err)
+	assert.Equal(t, same, got)
+
+	// A wider unit than the timestamps cannot represent them.
+	_, err = transcode.Transcode(block, transcode.Gorilla, transcode.Variant{Encoding: gorilla.Encoding{Unit: time.Minute}})
+	assert.EqualError(t, err, "failed to encode variant(unit=1m0s first-delta=14 buckets=7,9,12,32 values=xor): point 0: timestamp 1600000000 is not in whole 1m0s")
+	minutes := []gorilla.Point{{T: 1600000020, V: 1}, {T: 1600000080, V: 2}, {T: 1600003620, V: 3}}
+	_, err = transcode.Transcode(mustEncode(t, minutes), transcode.Gorilla, transcode.Variant{Encoding: gorilla.Encoding{Unit: time.Minute}})
+	require.Nil(t, err)
+
+	_, err = transcode.Variant{Encoding: gorilla.Encoding{Buckets: []int{4}}}.Encode(points)
+	assert.True(t, errors.Is(err, gorilla.ErrOutOfRange), err)
+	assert.EqualError(t, err, "point 1: failed to compress timestamp: timestamp out of range: delta of delta 15 exceeds 4 bits")
+	_, err = transcode.Variant{Encoding: gorilla.Encoding{Buckets: []int{9, 7}}}.Encode(points)
+	assert.NotNil(t, err)
+	_, err = transcode.Variant{Encoding: gorilla.Encoding{Unit: 7 * time.Millisecond / 10}}.Encode(points)
+	assert.NotNil(t, err)
+	_, err = transcode.Variant{}.Decode(b[:0])
+	assert.NotNil(t, err)
+}
+
+func Test_Transcode_errors(t *testing.T) {
+	_, err := transcode.Transcode([]byte{0x01}, transcode.Gorilla, transcode.Prometheus{})
+	assert.NotNil(t, err)
+
+	// A codec losing precision fails the verification.
+	_, err = transcode.Transcode(mustEncode(t, points), transcode.Gorilla, lossy{})
+	assert.True(t, errors.Is(err, transcode.ErrMismatch), err)
+}
+
+func mustEncode(t *testing.T, points []gorilla.Point) []byte {
+	t.Helper()
+	b, err := transcode.Gorilla.Encode(points)
+	require.Nil(t, err)
+	return b
+}
+
+// lossy changes values on encoding.
+type lossy struct{}
+
+func (lossy) Name() string {
+	return "lossy"
+}
+
+func (lossy) Encode(points []gorilla.Point) ([]byte, error) {
+	changed := make([]gorilla.Point, len(points))
+	for i, p := range points {
+		changed[i] = gorilla.Point{T: p.T, V: p.V + 0.1}
+	}
+	return transcode.Gorilla.Encode(changed)
+}
+
+func (lossy) Decode(b []byte) ([]gorilla.Point, error) {
+	return transcode.Gorilla.Decode(b)
+}
@@ -0,0 +1,341 @@
+package tsdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentMagic  = "GORS"
+	segmentSuffix = ".seg"
+	// footerSize is the size of the index offset (8 bytes), the CRC32-C of
+	// the index (4 bytes) and the magic (4 bytes) ending a segment file.
+	footerSize = 16
+)
+
+// ErrInvalidSegment is returned when a segment file is malformed.
+var ErrInvalidSegment = errors.New("invalid segment")
+
+// ErrOverlap is returned when writing a block which overlaps the time range of
+// another block of its series without being within it.
+var ErrOverlap = errors.New("overlapping block")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// BlockStore is a directory of immutable segment files holding sealed blocks.
+// Segments are memory-mapped and blocks are decompressed directly from the
+// mapped bytes. It is safe for concurrent use: queries can run while Write
+// adds new segments.
+type BlockStore struct {
+	dir string
+	// writeMu serializes Write, so blocks are checked against every block written before.
+	writeMu sync.Mutex
+
+	mu       sync.RWMutex
+	segments []*segment
+	series   map[string][]*Block
+	nextSeq  int
+}
+
+type segment struct {
+	path string
+	data []byte
+}
+
+// OpenBlockStore opens the block store in dir, creating dir if it does not exist.
+func OpenBlockStore(dir string) (*BlockStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	bs := &BlockStore{
+		dir:    dir,
+		series: make(map[string][]*Block),
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(name, segmentSuffix))
+		if err != nil {
+			continue
+		}
+		seg, blocks, err := openSegment(filepath.Join(dir, name))
+		if err != nil {
+			bs.Close()
+			return nil, err
+		}
+		bs.add(seg, blocks)
+		if bs.nextSeq <= seq {
+			bs.nextSeq = seq + 1
+		}
+	}
+	return bs, nil
+}
+
+// openSegment maps the segment file and decodes its blocks.
+func openSegment(path string) (*segment, map[string][]*Block, error) {
+	data, err := mmapFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to map segment %s: %w", path, err)
+	}
+	blocks, err := decodeSegment(data)
+	if err != nil {
+		munmap(data)
+		return nil, nil, fmt.Errorf("failed to open segment %s: %w", path, err)
+	}
+	return &segment{path: path, data: data}, blocks, nil
+}
+
+// add adds the segment and its blocks to the index.
+func (bs *BlockStore) add(seg *segment, blocks map[string][]*Block) {
+	bs.segments = append(bs.segments, seg)
+	for name, bb := range blocks {
+		s := append(bs.series[name], bb...)
+		sort.Slice(s, func(i, j int) bool { return s[i].MinTime < s[j].MinTime })
+		bs.series[name] = s
+	}
+}
+
+// Write writes the blocks keyed by series name to a new segment file. Blocks
+// within the time range of a block already written for their series are
+// skipped, so blocks sealed again after a restart are stored once. Write fails
+// with ErrOverlap if a block overlaps other blocks of its series otherwise,
+// since queries read the blocks of a series one after another.
+func (bs *BlockStore) Write(blocks map[string][]*Block) error {
+	bs.writeMu.Lock()
+	defer bs.writeMu.Unlock()
+
+	bs.mu.Lock()
+	blocks, err := bs.unwritten(blocks)
+	seq := bs.nextSeq
+	if err == nil && 0 < len(blocks) {
+		bs.nextSeq++
+	}
+	bs.mu.Unlock()
+	if err != nil || len(blocks) == 0 {
+		return err
+	}
+
+	path := filepath.Join(bs.dir, fmt.Sprintf("%08d%s", seq, segmentSuffix))
+	tmp := path + ".tmp"
+	if err := writeSegment(tmp, blocks); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rename segment: %w", err)
+	}
+	// The rename is durable once the directory is synced.
+	if err := syncDir(bs.dir); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	seg, segBlocks, err := openSegment(path)
+	if err != nil {
+		return err
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.add(seg, segBlocks)
+	return nil
+}
+
+// unwritten returns the blocks not within a block already written for their
+// series, or ErrOverlap if one of them overlaps another block.
+func (bs *BlockStore) unwritten(blocks map[string][]*Block) (map[string][]*Block, error) {
+	fresh := make(map[string][]*Block)
+	for name, bb := range blocks {
+		for _, b := range bb {
+			o := overlapping(bs.series[name], b)
+			if o != nil && o.MinTime <= b.MinTime && b.MaxTime <= o.MaxTime {
+				continue
+			}
+			if o == nil {
+				o = overlapping(fresh[name], b)
+			}
+			if o != nil {
+				return nil, fmt.Errorf("%w: %s [%d, %d] overlaps [%d, %d]", ErrOverlap, name, b.MinTime, b.MaxTime, o.MinTime, o.MaxTime)
+			}
+			fresh[name] = append(fresh[name], b)
+		}
+	}
+	return fresh, nil
+}
+
+// overlapping returns the first of blocks whose time range overlaps b, or nil.
+func overlapping(blocks []*Block, b *Block) *Block {
+	for _, o := range blocks {
+		if o.MinTime <= b.MaxTime && b.MinTime <= o.MaxTime {
+			return o
+		}
+	}
+	return nil
+}
+
+// writeSegment writes a segment file consisting of the magic, the block data,
+// the index and the footer. The index has an entry per block: the series name
+// length (uvarint), the series name, the min and max time (4 bytes each),
+// and the count, offset and length of the block data (uvarint each).
+func writeSegment(path string, blocks map[string][]*Block) error {
+	names := make([]string, 0, len(blocks))
+	for name := range blocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bytes.NewBufferString(segmentMagic)
+	var index []byte
+	var scratch [binary.MaxVarintLen64]byte
+	putUvarint := func(u uint64) {
+		index = append(index, scratch[:binary.PutUvarint(scratch[:], u)]...)
+	}
+	for _, name := range names {
+		for _, b := range blocks[name] {
+			putUvarint(uint64(len(name)))
+			index = append(index, name...)
+			binary.BigEndian.PutUint32(scratch[:], b.MinTime)
+			index = append(index, scratch[:4]...)
+			binary.BigEndian.PutUint32(scratch[:], b.MaxTime)
+			index = append(index, scratch[:4]...)
+			putUvarint(uint64(b.Count))
+			putUvarint(uint64(buf.Len()))
+			putUvarint(uint64(len(b.Data)))
+			buf.Write(b.Data)
+		}
+	}
+	var footer [footerSize]byte
+	binary.BigEndian.PutUint64(footer[:], uint64(buf.Len()))
+	binary.BigEndian.PutUint32(footer[8:], crc32.Checksum(index, castagnoli))
+	copy(footer[12:], segmentMagic)
+	buf.Write(index)
+	buf.Write(footer[:])
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync segment: %w", err)
+	}
+	return f.Close()
+}
+
+// decodeSegment returns the blocks of the segment keyed by series name.
+// The data of the blocks refers to data.
+func decodeSegment(data []byte) (map[string][]*Block, error) {
+	if len(data) < len(segmentMagic)+footerSize ||
+		string(data[:len(segmentMagic)]) != segmentMagic ||
+		string(data[len(data)-len(segmentMagic):]) != segmentMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidSegment)
+	}
+	footer := data[len(data)-footerSize:]
+	indexOffset := binary.BigEndian.Uint64(footer)
+	if indexOffset < uint64(len(segmentMagic)) || uint64(len(data)-footerSize) < indexOffset {
+		return nil, fmt.Errorf("%w: bad index offset", ErrInvalidSegment)
+	}
+	index := data[indexOffset : len(data)-footerSize]
+	if crc32.Checksum(index, castagnoli) != binary.BigEndian.Uint32(footer[8:]) {
+		return nil, fmt.Errorf("%w: index checksum mismatch", ErrInvalidSegment)
+	}
+
+	uvarint := func() (uint64, error) {
+		u, n := binary.Uvarint(index)
+		if n <= 0 {
+			return 0, fmt.Errorf("%w: bad index entry", ErrInvalidSegment)
+		}
+		index = index[n:]
+		return u, nil
+	}
+	blocks := make(map[string][]*Block)
+	for 0 < len(index) {
+		n, err := uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(index)) < n+8 {
+			return nil, fmt.Errorf("%w: bad index entry", ErrInvalidSegment)
+		}
+		name := string(index[:n])
+		b := &Block{
+			MinTime: binary.BigEndian.Uint32(index[n:]),
+			MaxTime: binary.BigEndian.Uint32(index[n+4:]),
+		}
+		index = index[n+8:]
+		var fields [3]uint64
+		for i := range fields {
+			if fields[i], err = uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		offset, length := fields[1], fields[2]
+		if indexOffset < offset+length || offset < uint64(len(segmentMagic)) {
+			return nil, fmt.Errorf("%w: block out of range", ErrInvalidSegment)
+		}
+		b.Count = int(fields[0])
+		b.Data = data[offset : offset+length : offset+length]
+		blocks[name] = append(blocks[name], b)
+	}
+	return blocks, nil
+}
+
+// Series returns the names of the series in the block store in ascending order.
+func (bs *BlockStore) Series() []string {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	names := make([]string, 0, len(bs.series))
+	for name := range bs.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query returns an iterator over the points of the series whose timestamps are within [from, to].
+// Write keeps the blocks of a series from overlapping, so they are read in time order.
+// The iterator must not be used after the block store is closed.
+func (bs *BlockStore) Query(name string, from, to uint32) *Iterator {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	it := &Iterator{from: from, to: to}
+	for _, b := range bs.series[name] {
+		if from <= b.MaxTime && b.MinTime <= to {
+			it.blocks = append(it.blocks, b.Data)
+		}
+	}
+	return it
+}
+
+// Close unmaps the segment files.
+func (bs *BlockStore) Close() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	var err error
+	for _, s := range bs.segments {
+		if e := munmap(s.data); e != nil && err == nil {
+			err = fmt.Errorf("failed to unmap segment %s: %w", s.path, e)
+		}
+	}
+	bs.segments = nil
+	bs.series = make(map[string][]*Block)
+	return err
+}
@@ -0,0 +1,131 @@
+package tsdb_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/keisku/gorilla/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sealedBlocks(t *testing.T, start uint32, n int) (map[string][]*tsdb.Block, []point) {
+	t.Helper()
+	sealed := make(map[string][]*tsdb.Block)
+	s, err := tsdb.NewStore(tsdb.Options{
+		BlockDuration: 600,
+		OnSeal: func(series string, b *tsdb.Block) {
+			sealed[series] = append(sealed[series], b)
+		},
+	})
+	require.Nil(t, err)
+	var points []point
+	for i := 0; i < n; i++ {
+		p := point{start + uint32(i*60), float64(i) * 0.5}
+		require.Nil(t, s.Append("cpu", p.t, p.v))
+		require.Nil(t, s.Append("mem", p.t, p.v))
+		points = append(points, p)
+	}
+	// Seal the last head block.
+	require.Nil(t, s.Append("cpu", start+uint32(n*60)+600, 0))
+	require.Nil(t, s.Append("mem", start+uint32(n*60)+600, 0))
+	return sealed, points
+}
+
+func Test_BlockStore_Write_Query(t *testing.T) {
+	dir := t.TempDir()
+	bs, err := tsdb.OpenBlockStore(dir)
+	require.Nil(t, err)
+
+	const start = uint32(1600000200)
+	first, expected := sealedBlocks(t, start, 30)
+	second, rest := sealedBlocks(t, start+3600, 30)
+	expected = append(expected, rest...)
+	require.Nil(t, bs.Write(first))
+	require.Nil(t, bs.Write(second))
+
+	assert.Equal(t, []string{"cpu", "mem"}, bs.Series())
+	assert.Equal(t, expected, collect(t, bs.Query("cpu", 0, start+7200)))
+	assert.Equal(t, expected[25:40], collect(t, bs.Query("mem", expected[25].t, expected[39].t)))
+	assert.Empty(t, collect(t, bs.Query("disk", 0, start+7200)))
+	require.Nil(t, bs.Close())
+
+	bs, err = tsdb.OpenBlockStore(dir)
+	require.Nil(t, err)
+	defer bs.Close()
+	assert.Equal(t, expected, collect(t, bs.Query("cpu", 0, start+7200)))
+
+	third, more := sealedBlocks(t, start+7200, 10)
+	require.Nil(t, bs.Write(third))
+	assert.Equal(t, append(expected, more...), collect(t, bs.Query("cpu", 0, start+10800)))
+}
+
+func Test_BlockStore_Write_Overlap(t *testing.T) {
+	dir := t.TempDir()
+	bs, err := tsdb.OpenBlockStore(dir)
+	require.Nil(t, err)
+	defer bs.Close()
+
+	const start = uint32(1600000200)
+	blocks, expected := sealedBlocks(t, start, 30)
+	require.Nil(t, bs.Write(blocks))
+	// Blocks within written ones are skipped, e.g. sealed again after a restart.
+	require.Nil(t, bs.Write(blocks))
+	within, _ := sealedBlocks(t, start+60, 5)
+	require.Nil(t, bs.Write(within))
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.Nil(t, err)
+	assert.Len(t, segments, 1)
+	assert.Equal(t, expected, collect(t, bs.Query("cpu", 0, start+3600)))
+
+	overlapping, _ := sealedBlocks(t, start+30, 10)
+	assert.True(t, errors.Is(bs.Write(overlapping), tsdb.ErrOverlap))
+	assert.Equal(t, expected, collect(t, bs.Query("cpu", 0, start+3600)))
+}
+
+func Test_BlockStore_ConcurrentReaders(t *testing.T) {
+	bs, err := tsdb.OpenBlockStore(t.TempDir())
+	require.Nil(t, err)
+	defer bs.Close()
+
+	const start = uint32(1600000200)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				it := bs.Query("cpu", 0, start+36000)
+				for it.Next() {
+				}
+				assert.Nil(t, it.Err())
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		blocks, _ := sealedBlocks(t, start+uint32(i*3600), 30)
+		require.Nil(t, bs.Write(blocks))
+	}
+	wg.Wait()
+}
+
+func Test_OpenBlockStore_InvalidSegment(t *testing.T) {
+	dir := t.TempDir()
+	bs, err := tsdb.OpenBlockStore(dir)
+	require.Nil(t, err)
+	blocks, _ := sealedBlocks(t, 1600000200, 30)
+	require.Nil(t, bs.Write(blocks))
+	require.Nil(t, bs.Close())
+
+	path := filepath.Join(dir, "00000000.seg")
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	b[len(b)-20] ^= 0xFF
+	require.Nil(t, os.WriteFile(path, b, 0o644))
+
+	_, err = tsdb.OpenBlockStore(dir)
+	assert.True(t, errors.Is(err, tsdb.ErrInvalidSegment))
+}
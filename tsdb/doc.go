@@ -0,0 +1,2 @@
+// Package tsdb provides in-memory and on-disk time-series stores built on gorilla blocks.
+package tsdb
@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package tsdb
+
+import "os"
+
+// mmapFile reads the whole file into memory on platforms without mmap support.
+func mmapFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func munmap(b []byte) error {
+	return nil
+}
+
+// syncDir does nothing on platforms where directories cannot be synced.
+func syncDir(dir string) error {
+	return nil
+}
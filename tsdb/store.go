@@ -0,0 +1,327 @@
+package tsdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/keisku/gorilla"
+	"github.com/keisku/gorilla/wal"
+)
+
+// DefaultBlockDuration is the time span of a block in seconds used when
+// Options.BlockDuration is zero. The paper shows two-hour blocks keep
+// the compression ratio high.
+const DefaultBlockDuration = 2 * 60 * 60
+
+// maxBlockDuration is the largest block duration whose first delta fits in the
+// 14 bits the compressor uses for it.
+const maxBlockDuration = 1<<14 - 1
+
+// ErrOutOfOrder is returned when appending a point older than the latest point of its series.
+var ErrOutOfOrder = errors.New("out of order point")
+
+// ErrOutOfRange is returned when appending a point at a timestamp blocks cannot store, i.e. zero.
+var ErrOutOfRange = gorilla.ErrOutOfRange
+
+// Appender appends points to series, e.g. a *Store.
+type Appender interface {
+	Append(series string, t uint32, v float64) error
+}
+
+// Options configures Store.
+type Options struct {
+	// BlockDuration is the time span in seconds covered by a block.
+	// Defaults to DefaultBlockDuration.
+	BlockDuration uint32
+	// Retention is how long in seconds sealed blocks are kept, counted back
+	// from the latest timestamp of their series. Zero keeps blocks forever.
+	Retention uint32
+	// WAL records appended points before they are compressed, so head blocks
+	// can be rebuilt after a crash. NewStore replays it when set.
+	WAL *wal.WAL
+	// OnSeal is called with each block sealed by Append, e.g. to persist it
+	// in a BlockStore. It must not call methods of the Store. It is also
+	// called with the blocks sealed while NewStore replays the WAL, since a
+	// crash can happen between logging a point and sealing its block. Those
+	// may have been handed to OnSeal before the restart, entirely or partly
+	// if the WAL was truncated, which BlockStore.Write skips.
+	OnSeal func(series string, b *Block)
+}
+
+// Block is a sealed gorilla block of a series.
+type Block struct {
+	MinTime uint32
+	MaxTime uint32
+	Count   int
+	Data    []byte
+}
+
+// Store is an in-memory time-series store keyed by series name.
+// Each series keeps an open head block and a list of sealed blocks.
+// It is safe for concurrent use.
+type Store struct {
+	blockDuration uint32
+	retention     uint32
+	wal           *wal.WAL
+	onSeal        func(series string, b *Block)
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+type series struct {
+	head    *head
+	blocks  []*Block
+	maxTime uint32
+}
+
+type head struct {
+	buf     *bytes.Buffer
+	c       *gorilla.Compressor
+	finish  func() error
+	header  uint32
+	minTime uint32
+	maxTime uint32
+	count   int
+}
+
+// NewStore returns an empty Store.
+func NewStore(opts Options) (*Store, error) {
+	if opts.BlockDuration == 0 {
+		opts.BlockDuration = DefaultBlockDuration
+	}
+	if opts.BlockDuration > maxBlockDuration {
+		return nil, fmt.Errorf("block duration must be at most %d seconds: %d", maxBlockDuration, opts.BlockDuration)
+	}
+	s := &Store{
+		blockDuration: opts.BlockDuration,
+		retention:     opts.Retention,
+		wal:           opts.WAL,
+		onSeal:        opts.OnSeal,
+		series:        make(map[string]*series),
+	}
+	if s.wal != nil {
+		if err := s.wal.Replay(s.append); err != nil {
+			return nil, fmt.Errorf("failed to replay WAL: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Append appends a point to the series, sealing its head block when t falls
+// out of the head's time window.
+func (s *Store) Append(name string, t uint32, v float64) error {
+	if t == 0 {
+		return fmt.Errorf("%w: %d", ErrOutOfRange, t)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal != nil {
+		if sr, ok := s.series[name]; ok && outOfOrder(sr, t) {
+			return fmt.Errorf("%w: %d is older than %d", ErrOutOfOrder, t, sr.maxTime)
+		}
+		if err := s.wal.Log(name, t, v); err != nil {
+			return fmt.Errorf("failed to log to WAL: %w", err)
+		}
+	}
+	return s.append(name, t, v)
+}
+
+func outOfOrder(sr *series, t uint32) bool {
+	return (sr.head != nil || 0 < len(sr.blocks)) && t < sr.maxTime
+}
+
+func (s *Store) append(name string, t uint32, v float64) error {
+	sr, ok := s.series[name]
+	if !ok {
+		sr = &series{}
+		s.series[name] = sr
+	}
+	if outOfOrder(sr, t) {
+		return fmt.Errorf("%w: %d is older than %d", ErrOutOfOrder, t, sr.maxTime)
+	}
+
+	if sr.head != nil && sr.head.header+s.blockDuration <= t {
+		if err := s.seal(name, sr); err != nil {
+			return err
+		}
+	}
+	if sr.head == nil {
+		h, err := newHead(t - t%s.blockDuration)
+		if err != nil {
+			return err
+		}
+		sr.head = h
+	}
+
+	if err := sr.head.c.Compress(t, v); err != nil {
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+	if sr.head.count == 0 {
+		sr.head.minTime = t
+	}
+	sr.head.maxTime = t
+	sr.head.count++
+	sr.maxTime = t
+	return nil
+}
+
+func newHead(header uint32) (*head, error) {
+	buf := new(bytes.Buffer)
+	c, finish, err := gorilla.NewCompressor(buf, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open head block: %w", err)
+	}
+	return &head{buf: buf, c: c, finish: finish, header: header}, nil
+}
+
+// seal finishes the head block of the series and evicts blocks out of retention.
+func (s *Store) seal(name string, sr *series) error {
+	h := sr.head
+	if err := h.finish(); err != nil {
+		return fmt.Errorf("failed to seal head block: %w", err)
+	}
+	b := &Block{
+		MinTime: h.minTime,
+		MaxTime: h.maxTime,
+		Count:   h.count,
+		Data:    h.buf.Bytes(),
+	}
+	sr.blocks = append(sr.blocks, b)
+	sr.head = nil
+	if s.onSeal != nil {
+		s.onSeal(name, b)
+	}
+
+	if s.retention == 0 || sr.maxTime < s.retention {
+		return nil
+	}
+	minTime := sr.maxTime - s.retention
+	i := 0
+	for i < len(sr.blocks) && sr.blocks[i].MaxTime < minTime {
+		i++
+	}
+	sr.blocks = sr.blocks[i:]
+	return nil
+}
+
+// Series returns the names of the series in the store in ascending order.
+func (s *Store) Series() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.series))
+	for name := range s.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TruncateWAL removes the WAL segments holding only points of sealed blocks.
+// Call it once sealed blocks are persisted, since they cannot be rebuilt
+// from the WAL afterwards.
+func (s *Store) TruncateWAL() error {
+	if s.wal == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var mint uint32 = math.MaxUint32
+	for _, sr := range s.series {
+		if sr.head != nil && sr.head.header < mint {
+			mint = sr.head.header
+		}
+	}
+	return s.wal.Truncate(mint)
+}
+
+// Query returns an iterator over the points of the series whose timestamps are within [from, to].
+func (s *Store) Query(name string, from, to uint32) *Iterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	it := &Iterator{from: from, to: to}
+	sr, ok := s.series[name]
+	if !ok {
+		return it
+	}
+	for _, b := range sr.blocks {
+		if from <= b.MaxTime && b.MinTime <= to {
+			it.blocks = append(it.blocks, b.Data)
+		}
+	}
+	if h := sr.head; h != nil && 0 < h.count && from <= h.maxTime && h.minTime <= to {
+		buf := bytes.NewBuffer(append([]byte{}, h.buf.Bytes()...))
+		if err := h.c.Snapshot(buf); err != nil {
+			it.err = fmt.Errorf("failed to snapshot head block: %w", err)
+			return it
+		}
+		it.blocks = append(it.blocks, buf.Bytes())
+	}
+	return it
+}
+
+// Iterator iterates over the points returned by Store.Query in time order.
+type Iterator struct {
+	blocks [][]byte
+	from   uint32
+	to     uint32
+	cur    *gorilla.DecompressIterator
+	t      uint32
+	v      float64
+	err    error
+}
+
+// Next advances the iterator and reports whether a point is available.
+func (it *Iterator) Next() bool {
+	for it.err == nil {
+		if it.cur == nil {
+			if len(it.blocks) == 0 {
+				return false
+			}
+			d, _, err := gorilla.NewDecompressor(bytes.NewReader(it.blocks[0]))
+			if err != nil {
+				it.err = fmt.Errorf("failed to open block: %w", err)
+				return false
+			}
+			it.blocks = it.blocks[1:]
+			it.cur = d.Iterator()
+		}
+		if !it.cur.Next() {
+			if err := it.cur.Err(); err != nil {
+				it.err = fmt.Errorf("failed to decompress block: %w", err)
+				return false
+			}
+			it.cur = nil
+			continue
+		}
+		t, v := it.cur.At()
+		if t < it.from {
+			continue
+		}
+		if it.to < t {
+			// Points are appended in time order, so no later point is in range.
+			it.blocks = nil
+			it.cur = nil
+			return false
+		}
+		it.t, it.v = t, v
+		return true
+	}
+	return false
+}
+
+// At returns the current point.
+func (it *Iterator) At() (t uint32, v float64) {
+	return it.t, it.v
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
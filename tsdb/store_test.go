@@ -0,0 +1,136 @@
+package tsdb_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/keisku/gorilla/tsdb"
+	"github.com/keisku/gorilla/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type point struct {
+	t uint32
+	v float64
+}
+
+func collect(t *testing.T, it *tsdb.Iterator) []point {
+	t.Helper()
+	var points []point
+	for it.Next() {
+		ts, v := it.At()
+		points = append(points, point{ts, v})
+	}
+	require.Nil(t, it.Err())
+	return points
+}
+
+func Test_Store_Append_Query(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{BlockDuration: 600})
+	require.Nil(t, err)
+
+	const start = uint32(1600000200)
+	var expected []point
+	for i := 0; i < 100; i++ {
+		p := point{start + uint32(i*60), float64(i) / 4}
+		require.Nil(t, s.Append("cpu", p.t, p.v))
+		require.Nil(t, s.Append("mem", p.t, -p.v))
+		expected = append(expected, p)
+	}
+
+	assert.Equal(t, expected, collect(t, s.Query("cpu", 0, start+100*60)))
+	assert.Equal(t, expected[10:21], collect(t, s.Query("cpu", expected[10].t, expected[20].t)))
+	assert.Equal(t, expected[95:], collect(t, s.Query("cpu", expected[95].t, expected[99].t+1)))
+	assert.Equal(t, -expected[3].v, collect(t, s.Query("mem", expected[3].t, expected[3].t))[0].v)
+	assert.Empty(t, collect(t, s.Query("disk", 0, start+100*60)))
+	assert.Empty(t, collect(t, s.Query("cpu", 0, start-1)))
+	assert.Equal(t, []string{"cpu", "mem"}, s.Series())
+}
+
+func Test_Store_Append_OutOfOrder(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	require.Nil(t, s.Append("cpu", 1600000000, 1))
+	require.Nil(t, s.Append("cpu", 1600000000, 2))
+	err = s.Append("cpu", 1599999999, 3)
+	assert.True(t, errors.Is(err, tsdb.ErrOutOfOrder))
+	assert.Equal(t, []point{{1600000000, 1}, {1600000000, 2}}, collect(t, s.Query("cpu", 0, 1700000000)))
+}
+
+func Test_Store_Append_OutOfRange(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{})
+	require.Nil(t, err)
+	err = s.Append("cpu", 0, 1)
+	assert.True(t, errors.Is(err, tsdb.ErrOutOfRange))
+	assert.Empty(t, s.Series())
+}
+
+func Test_Store_Retention(t *testing.T) {
+	s, err := tsdb.NewStore(tsdb.Options{BlockDuration: 600, Retention: 1200})
+	require.Nil(t, err)
+
+	const start = uint32(1600000200)
+	for i := 0; i < 60; i++ {
+		require.Nil(t, s.Append("cpu", start+uint32(i*60), float64(i)))
+	}
+	points := collect(t, s.Query("cpu", 0, start+3600))
+	require.NotEmpty(t, points)
+	assert.Equal(t, start+59*60, points[len(points)-1].t)
+	// Blocks are evicted when the head is sealed, so the oldest kept block ends
+	// within the retention counted back from the last sealed point.
+	assert.Equal(t, start+1200, points[0].t)
+}
+
+func Test_NewStore_InvalidBlockDuration(t *testing.T) {
+	_, err := tsdb.NewStore(tsdb.Options{BlockDuration: 1 << 14})
+	assert.NotNil(t, err)
+}
+
+func Test_Store_WAL(t *testing.T) {
+	dir := t.TempDir()
+	w, err := wal.Open(filepath.Join(dir, "wal"), wal.Options{SegmentSize: 1})
+	require.Nil(t, err)
+	bs, err := tsdb.OpenBlockStore(filepath.Join(dir, "blocks"))
+	require.Nil(t, err)
+	defer bs.Close()
+	sealed := 0
+	onSeal := func(series string, b *tsdb.Block) {
+		sealed++
+		assert.Nil(t, bs.Write(map[string][]*tsdb.Block{series: {b}}))
+	}
+	s, err := tsdb.NewStore(tsdb.Options{BlockDuration: 600, WAL: w, OnSeal: onSeal})
+	require.Nil(t, err)
+
+	const start = uint32(1600000200)
+	var expected []point
+	for i := 0; i < 25; i++ {
+		p := point{start + uint32(i*60), float64(i)}
+		require.Nil(t, s.Append("cpu", p.t, p.v))
+		expected = append(expected, p)
+	}
+	assert.True(t, errors.Is(s.Append("cpu", start, 0), tsdb.ErrOutOfOrder))
+	require.Nil(t, w.Close())
+	assert.Equal(t, 2, sealed)
+
+	// Restart without truncation rebuilds every point and seals the blocks
+	// again, which the block store skips.
+	w, err = wal.Open(filepath.Join(dir, "wal"), wal.Options{SegmentSize: 1})
+	require.Nil(t, err)
+	s, err = tsdb.NewStore(tsdb.Options{BlockDuration: 600, WAL: w, OnSeal: onSeal})
+	require.Nil(t, err)
+	assert.Equal(t, expected, collect(t, s.Query("cpu", 0, start+3600)))
+	assert.Equal(t, 4, sealed)
+	assert.Equal(t, expected[:20], collect(t, bs.Query("cpu", 0, start+3600)))
+
+	// Restart after truncation rebuilds the head block only.
+	require.Nil(t, s.TruncateWAL())
+	require.Nil(t, w.Close())
+	w, err = wal.Open(filepath.Join(dir, "wal"), wal.Options{SegmentSize: 1})
+	require.Nil(t, err)
+	defer w.Close()
+	s, err = tsdb.NewStore(tsdb.Options{BlockDuration: 600, WAL: w})
+	require.Nil(t, err)
+	assert.Equal(t, expected[20:], collect(t, s.Query("cpu", 0, start+3600)))
+}
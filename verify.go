@@ -0,0 +1,120 @@
+package gorilla
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrCorruptBlock is returned by Verify when a block is corrupted.
+var ErrCorruptBlock = errors.New("corrupt block")
+
+// Report is the result of Verify.
+type Report struct {
+	Header  uint32 `json:"header"`
+	Bytes   int    `json:"bytes"`
+	Points  int    `json:"points"`
+	MinTime uint32 `json:"minTime"`
+	MaxTime uint32 `json:"maxTime"`
+	// Problem describes the first problem of the block, or is empty if there is none.
+	Problem string `json:"problem,omitempty"`
+	// Offset is the bit offset of the first problem.
+	Offset uint64 `json:"offset,omitempty"`
+}
+
+// OK reports whether the block has no problem.
+func (r Report) OK() bool {
+	return r.Problem == ""
+}
+
+func (r Report) String() string {
+	if r.OK() {
+		return fmt.Sprintf("ok: header %d, %d points in [%d, %d], %d bytes", r.Header, r.Points, r.MinTime, r.MaxTime, r.Bytes)
+	}
+	return fmt.Sprintf("bit %d: %s", r.Offset, r.Problem)
+}
+
+// Verify decompresses the block read from r to its end and reports the first of
+// the following problems: an impossible bit header, a timestamp before the
+// previous one, a missing finish marker, and non-zero padding or bytes after it.
+// No block format of this module has a checksum, so there is none to verify.
+// The CRC-32C checksums of the files around blocks, of the records of wal and
+// of the index of tsdb.BlockStore segments, are verified where they are read.
+//
+// The returned error wraps ErrCorruptBlock if the report has a problem, or is
+// the error reading r.
+func Verify(r io.Reader) (Report, error) {
+	cr := &countingReader{r: r}
+	report, err := verify(cr)
+	if cr.err != nil {
+		return report, cr.err
+	}
+	report.Bytes = cr.n
+	if err != nil {
+		return report, err
+	}
+	if !report.OK() {
+		return report, fmt.Errorf("%w: %s", ErrCorruptBlock, report)
+	}
+	return report, nil
+}
+
+func verify(r io.Reader) (Report, error) {
+	d, header, err := NewDecompressor(r)
+	if err != nil {
+		return Report{Problem: "truncated header"}, nil
+	}
+	report := Report{Header: header}
+	problem := func(offset uint64, format string, args ...interface{}) {
+		if report.OK() {
+			report.Problem, report.Offset = fmt.Sprintf(format, args...), offset
+		}
+	}
+	windowed := false
+	offset, err := d.traceAll(func(p *pointTrace) {
+		if report.Points == 0 {
+			report.MinTime = p.t
+		} else if p.t < report.MaxTime {
+			problem(p.offset, "timestamp %d is before %d", p.t, report.MaxTime)
+		}
+		if p.valueCase == valueReuse && !windowed {
+			problem(p.offset, "value reuses a meaningful bits window before any is stored")
+		}
+		windowed = windowed || p.valueCase == valueNew
+		report.Points++
+		report.MaxTime = p.t
+	})
+	switch {
+	case errors.Is(err, errEndOfBlock):
+	case errors.Is(err, io.EOF):
+		problem(offset, "missing finish marker")
+		return report, nil
+	default:
+		problem(offset, "%v", err)
+		return report, nil
+	}
+
+	// The finish marker is followed by a zero value bit, or 64 zero value bits
+	// if the block has no points, and zero padding to the byte boundary.
+	tail := 1
+	if report.Points == 0 {
+		tail = 64
+	}
+	end := d.br.read
+	bits, err := d.br.readBits(tail)
+	if err != nil {
+		problem(end, "truncated finish marker")
+		return report, nil
+	}
+	if bits != 0 {
+		problem(end, "non-zero bits in finish marker")
+	}
+	if d.br.count != 0 && d.br.buffer[0] != 0 {
+		problem(d.br.read, "non-zero padding")
+	}
+	var b [1]byte
+	if n, _ := io.ReadFull(r, b[:]); n != 0 {
+		problem(d.br.read+uint64(d.br.count), "trailing bytes after finish marker")
+	}
+	return report, nil
+}
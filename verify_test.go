@@ -0,0 +1,86 @@
+package gorilla_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/keisku/gorilla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Verify(t *testing.T) {
+	b := compress(t, 1600000000, series(1600000000, 10, 60, 1))
+	report, err := gorilla.Verify(bytes.NewReader(b))
+	require.Nil(t, err)
+	assert.Equal(t, gorilla.Report{Header: 1600000000, Bytes: len(b), Points: 10, MinTime: 1600000000, MaxTime: 1600000540}, report)
+	assert.True(t, report.OK())
+
+	report, err = gorilla.Verify(bytes.NewReader(compress(t, 1600000000, nil)))
+	require.Nil(t, err)
+	assert.Equal(t, 0, report.Points)
+
+	_, err = gorilla.Verify(iotest.ErrReader(errors.New("disk error")))
+	assert.EqualError(t, err, "disk error")
+}
+
+func Test_Verify_problems(t *testing.T) {
+	b := compress(t, 1600000000, []point{{1600000000, 1}, {1600000060, 2}})
+	// header 32 bits, first point 14+64 bits, second point 9 bits of timestamp
+	// and 2+5+6+11 bits of value, then the finish marker.
+	const marker = 32 + 14 + 64 + 9 + 24
+
+	flip := func(b []byte, bit int) []byte {
+		c := append([]byte(nil), b...)
+		c[bit/8] ^= 0x80 >> (bit % 8)
+		return c
+	}
+	tests := []struct {
+		name    string
+		block   []byte
+		problem string
+		offset  uint64
+	}{
+		{"truncated header", b[:3], "truncated header", 0},
+		{"missing finish marker", b[:len(b)-2], "missing finish marker", marker},
+		{"trailing bytes", append(append([]byte(nil), b...), 0), "trailing bytes after finish marker", uint64(len(b) * 8)},
+		{"non-zero padding", flip(b, len(b)*8-1), "non-zero padding", marker + 36 + 1},
+		{"non-zero value bit of finish marker", flip(b, marker+36), "non-zero bits in finish marker", marker + 36},
+		// '10' 7 bits dod: 0111100 (60) -> 1111100 (-4) makes the timestamp go back.
+		{"timestamp going back", flip(b, 32+14+64+2), "timestamp 1599999996 is before 1600000000", 32 + 14 + 64},
+		// Points at the same timestamp, which tsdb.Store appends, are no problem.
+		{"equal timestamps", compress(t, 1600000000, []point{{1600000000, 1}, {1600000060, 2}, {1600000060, 3}}), "", 0},
+		// '11' -> '10' reuses the window before any is stored.
+		{"reused window", flip(compress(t, 1600000000, series(1600000000, 10, 60, 1)), 32+14+64+9+1), "value reuses a meaningful bits window before any is stored", 32 + 14 + 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := gorilla.Verify(bytes.NewReader(tt.block))
+			if tt.problem == "" {
+				require.Nil(t, err)
+				assert.True(t, report.OK())
+				return
+			}
+			assert.True(t, errors.Is(err, gorilla.ErrCorruptBlock), err)
+			assert.False(t, report.OK())
+			assert.Equal(t, tt.problem, report.Problem)
+			assert.Equal(t, tt.offset, report.Offset)
+		})
+	}
+}
+
+func Test_Verify_invalidWindow(t *testing.T) {
+	// A new window of 31 leading zeros and 63 significant bits cannot exist.
+	b := compress(t, 1600000000, []point{{1600000000, 1}, {1600000060, 2}})
+	c := append([]byte(nil), b...)
+	start := 32 + 14 + 64 + 9 + 2
+	for i := 0; i < 5+6; i++ {
+		bit := start + i
+		c[bit/8] |= 0x80 >> (bit % 8)
+	}
+	report, err := gorilla.Verify(bytes.NewReader(c))
+	assert.True(t, errors.Is(err, gorilla.ErrCorruptBlock), err)
+	assert.Contains(t, report.Problem, "invalid meaningful bits")
+}
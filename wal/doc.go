@@ -0,0 +1,2 @@
+// Package wal provides a write-ahead log recording points before they are compressed.
+package wal
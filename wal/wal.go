@@ -0,0 +1,313 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultSegmentSize is the size in bytes a segment grows to before a new one
+// is started, used when Options.SegmentSize is zero.
+const DefaultSegmentSize = 64 << 20
+
+// recordHeaderSize is the size of the payload length and checksum preceding each payload.
+const recordHeaderSize = 8
+
+// ErrCorrupt is returned when a record does not match its checksum.
+var ErrCorrupt = errors.New("corrupt record")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Options configures WAL.
+type Options struct {
+	// SegmentSize is the size in bytes a segment grows to before a new one is started.
+	// Defaults to DefaultSegmentSize.
+	SegmentSize int64
+}
+
+// WAL is a write-ahead log split into numbered segment files in a directory.
+// Each record holds a point of a series and is checksummed with CRC32-C.
+// It is safe for concurrent use.
+type WAL struct {
+	dir         string
+	segmentSize int64
+
+	mu sync.Mutex
+	f  *os.File
+	// seq is the number of the segment being written.
+	seq int
+	// size is the size of the segment being written.
+	size int64
+	// maxTimes has the latest timestamp recorded in each segment known to the WAL.
+	maxTimes map[int]uint32
+	buf      []byte
+}
+
+// Open opens the WAL in dir, creating dir if it does not exist.
+// Records are appended to a new segment following the existing ones,
+// which can be read by Replay.
+func Open(dir string, opts Options) (*WAL, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	seqs, err := segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &WAL{
+		dir:         dir,
+		segmentSize: opts.SegmentSize,
+		maxTimes:    make(map[int]uint32),
+	}
+	seq := 0
+	if 0 < len(seqs) {
+		seq = seqs[len(seqs)-1] + 1
+	}
+	if err := w.openSegment(seq); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// segments returns the numbers of the segment files in dir in ascending order.
+func segments(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	var seqs []int
+	for _, e := range entries {
+		seq, err := strconv.Atoi(e.Name())
+		if err != nil || e.IsDir() {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+func (w *WAL) segmentPath(seq int) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%08d", seq))
+}
+
+func (w *WAL) openSegment(seq int) error {
+	f, err := os.OpenFile(w.segmentPath(seq), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	w.f = f
+	w.seq = seq
+	w.size = 0
+	return nil
+}
+
+// Log appends a record of the point to the WAL. The record is written to the
+// segment file before Log returns, so it survives a crash of the process;
+// call Sync to make it survive a crash of the machine.
+func (w *WAL) Log(series string, t uint32, v float64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	if w.segmentSize <= w.size {
+		if err := w.cut(); err != nil {
+			return err
+		}
+	}
+
+	w.buf = encodeRecord(w.buf[:0], series, t, v)
+	n, err := w.f.Write(w.buf)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	if maxTime, ok := w.maxTimes[w.seq]; !ok || maxTime < t {
+		w.maxTimes[w.seq] = t
+	}
+	return nil
+}
+
+// encodeRecord appends the record of the point to b. A record consists of
+// the payload length (4 bytes), the CRC32-C of the payload (4 bytes) and the payload:
+// the series name length (uvarint), the series name, t (4 bytes) and v (8 bytes).
+func encodeRecord(b []byte, series string, t uint32, v float64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	start := len(b)
+	b = append(b, make([]byte, recordHeaderSize)...)
+	b = append(b, scratch[:binary.PutUvarint(scratch[:], uint64(len(series)))]...)
+	b = append(b, series...)
+	binary.BigEndian.PutUint32(scratch[:], t)
+	b = append(b, scratch[:4]...)
+	binary.BigEndian.PutUint64(scratch[:], math.Float64bits(v))
+	b = append(b, scratch[:8]...)
+	payload := b[start+recordHeaderSize:]
+	binary.BigEndian.PutUint32(b[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[start+4:], crc32.Checksum(payload, castagnoli))
+	return b
+}
+
+// decodeRecord decodes the record at the start of b and returns its size.
+func decodeRecord(b []byte) (n int, series string, t uint32, v float64, err error) {
+	if len(b) < recordHeaderSize {
+		return 0, "", 0, 0, fmt.Errorf("%w: torn record header", ErrCorrupt)
+	}
+	n = recordHeaderSize + int(binary.BigEndian.Uint32(b))
+	if len(b) < n || n < recordHeaderSize {
+		return 0, "", 0, 0, fmt.Errorf("%w: torn record", ErrCorrupt)
+	}
+	payload := b[recordHeaderSize:n]
+	if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(b[4:]) {
+		return 0, "", 0, 0, ErrCorrupt
+	}
+	series, t, v, err = decodePayload(payload)
+	return n, series, t, v, err
+}
+
+func decodePayload(payload []byte) (series string, t uint32, v float64, err error) {
+	n, k := binary.Uvarint(payload)
+	if k <= 0 || uint64(len(payload)-k) != n+12 {
+		return "", 0, 0, fmt.Errorf("%w: invalid payload length", ErrCorrupt)
+	}
+	payload = payload[k:]
+	series = string(payload[:n])
+	t = binary.BigEndian.Uint32(payload[n:])
+	v = math.Float64frombits(binary.BigEndian.Uint64(payload[n+4:]))
+	return series, t, v, nil
+}
+
+// cut closes the segment being written and starts a new one.
+func (w *WAL) cut() error {
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("failed to close segment: %w", err)
+	}
+	return w.openSegment(w.seq + 1)
+}
+
+// Sync commits the records written so far to stable storage.
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	return w.f.Sync()
+}
+
+// Replay calls fn for each record of the segments that existed when the WAL
+// was opened, in the order they were logged. A crash can leave a torn record,
+// or a tail of zeros or garbage, at the end of the last segment written to;
+// the last segment is truncated at its first torn or corrupt record.
+// Corruption in a segment followed by others fails with ErrCorrupt.
+func (w *WAL) Replay(fn func(series string, t uint32, v float64) error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seqs, err := segments(w.dir)
+	if err != nil {
+		return err
+	}
+	var replayed []int
+	for _, seq := range seqs {
+		if seq < w.seq {
+			replayed = append(replayed, seq)
+		}
+	}
+	// The last segment written to may be followed by empty segments of
+	// processes which crashed before logging anything.
+	last := len(replayed) - 1
+	for ; 0 < last; last-- {
+		info, err := os.Stat(w.segmentPath(replayed[last]))
+		if err != nil {
+			return err
+		}
+		if info.Size() != 0 {
+			break
+		}
+	}
+	for i, seq := range replayed {
+		if err := w.replaySegment(seq, last <= i, fn); err != nil {
+			return fmt.Errorf("failed to replay segment %d: %w", seq, err)
+		}
+	}
+	return nil
+}
+
+// replaySegment replays the records of the segment. A torn or corrupt record
+// truncates the segment there if tail is true, and fails with ErrCorrupt otherwise.
+func (w *WAL) replaySegment(seq int, tail bool, fn func(series string, t uint32, v float64) error) error {
+	b, err := os.ReadFile(w.segmentPath(seq))
+	if err != nil {
+		return err
+	}
+	var offset int
+	for offset < len(b) {
+		n, series, t, v, err := decodeRecord(b[offset:])
+		if err != nil {
+			if tail {
+				break
+			}
+			return fmt.Errorf("%w at offset %d", err, offset)
+		}
+		if err := fn(series, t, v); err != nil {
+			return err
+		}
+		if maxTime, ok := w.maxTimes[seq]; !ok || maxTime < t {
+			w.maxTimes[seq] = t
+		}
+		offset += n
+	}
+	if offset < len(b) {
+		if err := os.Truncate(w.segmentPath(seq), int64(offset)); err != nil {
+			return fmt.Errorf("failed to remove torn record: %w", err)
+		}
+	}
+	return nil
+}
+
+// Truncate removes the segments whose records are all older than mint.
+// The segment being written and segments not replayed are kept.
+func (w *WAL) Truncate(mint uint32) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f != nil && 0 < w.size {
+		if err := w.cut(); err != nil {
+			return err
+		}
+	}
+	for seq, maxTime := range w.maxTimes {
+		if seq == w.seq || mint <= maxTime {
+			continue
+		}
+		if err := os.Remove(w.segmentPath(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove segment %d: %w", seq, err)
+		}
+		delete(w.maxTimes, seq)
+	}
+	return nil
+}
+
+// Close closes the segment being written.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
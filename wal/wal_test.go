@@ -0,0 +1,167 @@
+package wal_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keisku/gorilla/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	series string
+	t      uint32
+	v      float64
+}
+
+func replay(t *testing.T, w *wal.WAL) []record {
+	t.Helper()
+	var records []record
+	require.Nil(t, w.Replay(func(series string, ts uint32, v float64) error {
+		records = append(records, record{series, ts, v})
+		return nil
+	}))
+	return records
+}
+
+func Test_WAL_Log_Replay(t *testing.T) {
+	dir := t.TempDir()
+	w, err := wal.Open(dir, wal.Options{SegmentSize: 100})
+	require.Nil(t, err)
+	var expected []record
+	for i := 0; i < 50; i++ {
+		r := record{"cpu", uint32(1600000000 + i), float64(i) / 3}
+		if i%2 == 0 {
+			r.series = "mem"
+		}
+		require.Nil(t, w.Log(r.series, r.t, r.v))
+		expected = append(expected, r)
+	}
+	require.Nil(t, w.Sync())
+	require.Nil(t, w.Close())
+
+	entries, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Greater(t, len(entries), 1)
+
+	w, err = wal.Open(dir, wal.Options{SegmentSize: 100})
+	require.Nil(t, err)
+	defer w.Close()
+	assert.Equal(t, expected, replay(t, w))
+}
+
+func Test_WAL_Replay_TornRecord(t *testing.T) {
+	dir := t.TempDir()
+	w, err := wal.Open(dir, wal.Options{})
+	require.Nil(t, err)
+	require.Nil(t, w.Log("cpu", 1600000000, 1))
+	require.Nil(t, w.Log("cpu", 1600000001, 2))
+	require.Nil(t, w.Close())
+
+	path := filepath.Join(dir, "00000000")
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	require.Nil(t, os.Truncate(path, info.Size()-3))
+
+	for i := 0; i < 2; i++ {
+		w, err = wal.Open(dir, wal.Options{})
+		require.Nil(t, err)
+		assert.Equal(t, []record{{"cpu", 1600000000, 1}}, replay(t, w))
+		require.Nil(t, w.Close())
+	}
+}
+
+func Test_WAL_Replay_CorruptTail(t *testing.T) {
+	dir := t.TempDir()
+	w, err := wal.Open(dir, wal.Options{})
+	require.Nil(t, err)
+	require.Nil(t, w.Log("cpu", 1600000000, 1))
+	require.Nil(t, w.Log("cpu", 1600000001, 2))
+	require.Nil(t, w.Close())
+
+	path := filepath.Join(dir, "00000000")
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	size := len(b)
+	b[len(b)-1] ^= 0xFF
+	// A crash can leave zeros after the last record, e.g. of preallocated blocks.
+	b = append(b, make([]byte, 32)...)
+	require.Nil(t, os.WriteFile(path, b, 0o644))
+
+	for i := 0; i < 2; i++ {
+		w, err = wal.Open(dir, wal.Options{})
+		require.Nil(t, err)
+		assert.Equal(t, []record{{"cpu", 1600000000, 1}}, replay(t, w))
+		require.Nil(t, w.Close())
+	}
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	assert.Equal(t, int64(size/2), info.Size())
+}
+
+func Test_WAL_Replay_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	w, err := wal.Open(dir, wal.Options{SegmentSize: 1})
+	require.Nil(t, err)
+	require.Nil(t, w.Log("cpu", 1600000000, 1))
+	require.Nil(t, w.Log("cpu", 1600000001, 2))
+	require.Nil(t, w.Close())
+
+	path := filepath.Join(dir, "00000000")
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	b[len(b)-1] ^= 0xFF
+	require.Nil(t, os.WriteFile(path, b, 0o644))
+
+	w, err = wal.Open(dir, wal.Options{})
+	require.Nil(t, err)
+	defer w.Close()
+	err = w.Replay(func(string, uint32, float64) error { return nil })
+	assert.True(t, errors.Is(err, wal.ErrCorrupt))
+}
+
+func Test_WAL_Replay_CorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	// Each record takes 24 bytes, so the first segment holds two records.
+	w, err := wal.Open(dir, wal.Options{SegmentSize: 48})
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, w.Log("cpu", uint32(1600000000+i), float64(i)))
+	}
+	require.Nil(t, w.Close())
+
+	path := filepath.Join(dir, "00000000")
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Len(t, b, 48)
+	b[0] = 0xFF
+	require.Nil(t, os.WriteFile(path, b, 0o644))
+
+	w, err = wal.Open(dir, wal.Options{})
+	require.Nil(t, err)
+	defer w.Close()
+	err = w.Replay(func(string, uint32, float64) error { return nil })
+	assert.True(t, errors.Is(err, wal.ErrCorrupt))
+	assert.Contains(t, err.Error(), "at offset 0")
+}
+
+func Test_WAL_Truncate(t *testing.T) {
+	dir := t.TempDir()
+	w, err := wal.Open(dir, wal.Options{SegmentSize: 1})
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		require.Nil(t, w.Log("cpu", uint32(1600000000+i), float64(i)))
+	}
+	require.Nil(t, w.Truncate(1600000005))
+	require.Nil(t, w.Close())
+
+	w, err = wal.Open(dir, wal.Options{})
+	require.Nil(t, err)
+	defer w.Close()
+	records := replay(t, w)
+	require.Len(t, records, 5)
+	assert.Equal(t, uint32(1600000005), records[0].t)
+}